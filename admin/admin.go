@@ -1,47 +1,185 @@
 package admin
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/boj/redistore"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/auth"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/cache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/compress"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/mail"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passkeys"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/sso"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/tokens"
+	"github.com/jasonsnider/com.jasonsnider.go/templates"
 )
 
 type App struct {
-	DB           *pgxpool.Pool
-	BustCssCache string
-	BustJsCache  string
-	SessionStore *redistore.RediStore
+	DB                   *pgxpool.Pool
+	SessionStore         *redistore.RediStore
+	Sessions             *auth.Sessions
+	Templates            *templates.Registry
+	Pages                *cache.RenderCache
+	Limiter              *throttle.Limiter
+	Mail                 mail.Sender
+	Tokens               *tokens.Signer
+	Passwords            *passwords.Policy
+	Registration         string
+	InvitationTTL        time.Duration
+	ResetTTL             time.Duration
+	VerificationTTL      time.Duration
+	RequireVerifiedEmail bool
+	BaseURL              string
+	Issuer               string
+	TwoFactorRoles       map[string]bool
+	WebAuthn             *webauthn.WebAuthn
+	SSO                  *sso.Provider
+	SSOName              string
+	SSOProvision         bool
+	SSODefaultRole       string
 	//SessionStore *sessions.CookieStore
 }
 
-func AdminRouter(dbpool *pgxpool.Pool) *mux.Router {
-
-	store, err := redistore.NewRediStore(10, "tcp", "redis:6379", "", []byte("your-secret-key"))
-
-	if err != nil {
-		log.Fatalf("Failed to initialize Redis store: %v", err)
-	}
+func AdminRouter(dbpool *pgxpool.Pool, tmpl *templates.Registry, store *redistore.RediStore, pages *cache.RenderCache, limiter *throttle.Limiter, mailer mail.Sender, signer *tokens.Signer, policy *passwords.Policy) *mux.Router {
 
 	// Initialize middleware
-	auth := &auth.AuthMiddleware{SessionStore: store}
+	sessions := auth.NewSessions(store.Pool)
+	auth := &auth.AuthMiddleware{SessionStore: store, Sessions: sessions}
+	auth.Role = func(email string) (string, error) {
+		user, err := (&db.DB{DB: dbpool}).FetchUserByEmail(email)
+		return user.Role, err
+	}
+	protect := csrf.New(store, "com-jasonsnider-go")
 
 	app := &App{
 		DB:           dbpool,
-		BustCssCache: cache.BustCssCache(),
-		BustJsCache:  cache.BustJsCache(),
 		SessionStore: store,
+		Sessions:     sessions,
+		Templates:    tmpl,
+		Pages:        pages,
+		Limiter:      limiter,
+		Mail:         mailer,
+		Tokens:       signer,
+		Passwords:    policy,
+		Registration: os.Getenv("REGISTRATION"),
+		BaseURL:      strings.TrimSuffix(os.Getenv("APP_URL"), "/"),
+	}
+
+	if app.BaseURL == "" {
+		log.Print("APP_URL is not set; invitations, password resets and email verification are off")
+	}
+
+	if app.Registration == "" {
+		app.Registration = RegistrationInvite
+	}
+
+	hours, err := strconv.Atoi(os.Getenv("INVITATION_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 72
+	}
+	app.InvitationTTL = time.Duration(hours) * time.Hour
+
+	minutes, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		minutes = 60
+	}
+	app.ResetTTL = time.Duration(minutes) * time.Minute
+
+	hours, err = strconv.Atoi(os.Getenv("EMAIL_VERIFICATION_TTL_HOURS"))
+	if err != nil || hours <= 0 {
+		hours = 48
+	}
+	app.VerificationTTL = time.Duration(hours) * time.Hour
+	app.RequireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	app.Issuer = os.Getenv("APP_NAME")
+	if app.Issuer == "" {
+		app.Issuer = "com-jasonsnider-go"
+	}
+
+	rp, err := passkeys.New(app.Issuer, app.BaseURL)
+	if err != nil {
+		log.Printf("Passkeys are off: %v", err)
+	} else {
+		app.WebAuthn = rp
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		app.SSOName = os.Getenv("OIDC_NAME")
+		if app.SSOName == "" {
+			app.SSOName = "single sign-on"
+		}
+
+		app.SSOProvision = os.Getenv("OIDC_PROVISION") == "true"
+		app.SSODefaultRole = os.Getenv("OIDC_DEFAULT_ROLE")
+		if app.SSODefaultRole != "admin" && app.SSODefaultRole != "author" {
+			app.SSODefaultRole = "user"
+		}
+
+		// Provisioned accounts are kept out of user management by their
+		// role, so they must not start as admins
+		if app.SSOProvision && app.SSODefaultRole == "admin" {
+			log.Print("OIDC_PROVISION is off: it would make every account at the provider an admin")
+			app.SSOProvision = false
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		provider, err := sso.New(ctx, sso.Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  app.BaseURL + "/admin/login/sso/callback",
+		})
+		cancel()
+
+		if err != nil {
+			log.Printf("Single sign-on is off: %v", err)
+		} else {
+			app.SSO = provider
+		}
+	}
+
+	app.TwoFactorRoles = make(map[string]bool)
+	for _, role := range strings.Split(os.Getenv("TWO_FACTOR_REQUIRED_ROLES"), ",") {
+		role = strings.TrimSpace(role)
+		if role != "" {
+			app.TwoFactorRoles[role] = true
+		}
 	}
 
 	router := mux.NewRouter()
+	router.Use(compress.Handler)
+	router.Use(protect.Handler)
 
 	router.HandleFunc("/admin/login", app.Authenticate).Methods("GET")
 	router.HandleFunc("/admin/login", app.Authenticate).Methods("POST")
-	router.HandleFunc("/admin/logout", app.Logout).Methods("GET")
+	router.HandleFunc("/admin/login/2fa", app.LoginTwoFactor).Methods("GET")
+	router.HandleFunc("/admin/login/2fa", app.LoginTwoFactor).Methods("POST")
+	router.HandleFunc("/admin/login/passkey/options", app.PasskeyLoginOptions).Methods("POST")
+	router.HandleFunc("/admin/login/passkey", app.PasskeyLogin).Methods("POST")
+	router.HandleFunc("/admin/login/sso", app.SSOLogin).Methods("GET")
+	router.HandleFunc("/admin/login/sso/callback", app.SSOCallback).Methods("GET")
+	router.HandleFunc("/admin/logout", app.Logout).Methods("POST")
+
+	router.HandleFunc("/admin/forgot-password", app.ForgotPassword).Methods("GET")
+	router.HandleFunc("/admin/forgot-password", app.ForgotPassword).Methods("POST")
+	router.HandleFunc("/admin/reset-password", app.ResetPassword).Methods("GET")
+	router.HandleFunc("/admin/reset-password", app.ResetPassword).Methods("POST")
+
+	router.HandleFunc("/admin/verify-email", app.VerifyEmail).Methods("GET")
 
 	router.HandleFunc("/admin/register", app.RegisterUser).Methods("GET")
 	router.HandleFunc("/admin/register", app.RegisterUser).Methods("POST")
@@ -51,21 +189,57 @@ func AdminRouter(dbpool *pgxpool.Pool) *mux.Router {
 
 	protected.HandleFunc("/dashboard", app.Dashboard).Methods("GET")
 
-	protected.HandleFunc("/users/create", app.CreateUser).Methods("GET")
-	protected.HandleFunc("/users/create", app.CreateUser).Methods("POST")
-	protected.HandleFunc("/users", app.ListUsers).Methods("GET")
-	protected.HandleFunc("/users/{id}", app.ViewUser).Methods("GET")
-	protected.HandleFunc("/users/{id}/edit", app.UpdateUser).Methods("GET")
-	protected.HandleFunc("/users/{id}/edit", app.UpdateUser).Methods("POST")
-	protected.HandleFunc("/users/{id}/delete", app.DeleteUser).Methods("GET")
-
-	protected.HandleFunc("/articles/create", app.CreateArticle).Methods("GET")
-	protected.HandleFunc("/articles/create", app.CreateArticle).Methods("POST")
-	protected.HandleFunc("/articles", app.ListArticles).Methods("GET")
-	protected.HandleFunc("/articles/{id}", app.ViewArticle).Methods("GET")
-	protected.HandleFunc("/articles/{id}/edit", app.UpdateArticle).Methods("GET")
-	protected.HandleFunc("/articles/{id}/edit", app.UpdateArticle).Methods("POST")
-	protected.HandleFunc("/articles/{id}/delete", app.DeleteArticle).Methods("GET")
+	protected.HandleFunc("/account/2fa", app.AccountTwoFactor).Methods("GET")
+	protected.HandleFunc("/account/2fa", app.AccountTwoFactor).Methods("POST")
+	protected.HandleFunc("/account/passkeys", app.ListPasskeys).Methods("GET")
+	protected.HandleFunc("/account/passkeys", app.CreatePasskey).Methods("POST")
+	protected.HandleFunc("/account/passkeys/options", app.PasskeyRegistrationOptions).Methods("POST")
+	protected.HandleFunc("/account/passkeys/{id}/delete", app.DeletePasskey).Methods("POST")
+	protected.HandleFunc("/account/sessions", app.ListSessions).Methods("GET")
+	protected.HandleFunc("/account/sessions/end", app.EndOtherSessions).Methods("POST")
+	protected.HandleFunc("/account/sessions/{handle}/end", app.EndSession).Methods("POST")
+
+	// Managing users, invitations included, and reading the audit log are
+	// for admins only
+	users := protected.PathPrefix("/users").Subrouter()
+	users.Use(auth.RoleRequired("admin"))
+
+	users.HandleFunc("/invitations", app.ListInvitations).Methods("GET")
+	users.HandleFunc("/invitations/create", app.CreateInvitation).Methods("GET")
+	users.HandleFunc("/invitations/create", app.CreateInvitation).Methods("POST")
+	users.HandleFunc("/invitations/{id}/delete", app.DeleteInvitation).Methods("POST")
+
+	users.HandleFunc("/create", app.CreateUser).Methods("GET")
+	users.HandleFunc("/create", app.CreateUser).Methods("POST")
+	users.HandleFunc("", app.ListUsers).Methods("GET")
+	users.HandleFunc("/{id}", app.ViewUser).Methods("GET")
+	users.HandleFunc("/{id}/edit", app.UpdateUser).Methods("GET")
+	users.HandleFunc("/{id}/edit", app.UpdateUser).Methods("POST")
+	users.HandleFunc("/{id}/delete", app.ConfirmDeleteUser).Methods("GET")
+	users.HandleFunc("/{id}/delete", app.DeleteUser).Methods("POST")
+	users.HandleFunc("/{id}/unlock", app.UnlockUser).Methods("POST")
+	users.HandleFunc("/{id}/verify", app.SendVerification).Methods("POST")
+	users.HandleFunc("/{id}/2fa/reset", app.ResetTwoFactor).Methods("POST")
+	users.HandleFunc("/{id}/sessions/end", app.EndUserSessions).Methods("POST")
+
+	audit := protected.PathPrefix("/audit").Subrouter()
+	audit.Use(auth.RoleRequired("admin"))
+
+	audit.HandleFunc("", app.ListAuditEvents).Methods("GET")
+	audit.HandleFunc("/export.csv", app.ExportAuditEvents).Methods("GET")
+
+	// Articles, drafts included, are for the roles that write the site
+	articles := protected.PathPrefix("/articles").Subrouter()
+	articles.Use(auth.RoleRequired("admin", "author"))
+
+	articles.HandleFunc("/create", app.CreateArticle).Methods("GET")
+	articles.HandleFunc("/create", app.CreateArticle).Methods("POST")
+	articles.HandleFunc("", app.ListArticles).Methods("GET")
+	articles.HandleFunc("/{id}", app.ViewArticle).Methods("GET")
+	articles.HandleFunc("/{id}/edit", app.UpdateArticle).Methods("GET")
+	articles.HandleFunc("/{id}/edit", app.UpdateArticle).Methods("POST")
+	articles.HandleFunc("/{id}/delete", app.ConfirmDeleteArticle).Methods("GET")
+	articles.HandleFunc("/{id}/delete", app.DeleteArticle).Methods("POST")
 
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("404 Not Found: %s", r.URL.Path)
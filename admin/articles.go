@@ -4,37 +4,38 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/gomarkdown/markdown"
 	"github.com/gorilla/mux"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/inflection"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/render"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 )
 
 type ArticlesPageData struct {
-	Title        string
-	Description  sql.NullString
-	Keywords     sql.NullString
-	Articles     []types.Article
-	BustCssCache string
-	BustJsCache  string
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Articles    []types.Article
+	Nonce       string
+	CSRF        string
 }
 
 type ArticlePageData struct {
-	ID           string
-	Title        string
-	Description  sql.NullString
-	Keywords     sql.NullString
-	Body         string
-	BustCssCache string
-	BustJsCache  string
+	ID          string
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Body        string
+	Format      string
+	Nonce       string
+	CSRF        string
 }
 
 type ArticleUpdateTemplate struct {
@@ -44,12 +45,9 @@ type ArticleUpdateTemplate struct {
 	Body             string
 	ValidationErrors map[string]string
 	Article          types.Article
-	BustCssCache     string
-	BustJsCache      string
-}
-
-func mdToHTML(md string) template.HTML {
-	return template.HTML(markdown.ToHTML([]byte(md), nil, nil))
+	Formats          []render.Format
+	Nonce            string
+	CSRF             string
 }
 
 func parseTime(timeStr string) *time.Time {
@@ -71,7 +69,7 @@ func (app *App) CreateArticle(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "POST" {
 		validate := validator.New()
-		validate.RegisterValidation("uniqueEmail", db.UniqueEmail)
+		validate.RegisterValidation("articleFormat", types.ArticleFormat)
 
 		article = types.Article{
 			Title: r.FormValue("title"),
@@ -101,44 +99,28 @@ func (app *App) CreateArticle(w http.ResponseWriter, r *http.Request) {
 				log.Fatalf("failed to create article: %v", err)
 			}
 
+			app.audit(r, types.AuditEvent{
+				Actor:      app.actor(r),
+				Action:     "article.created",
+				TargetType: "article",
+				TargetID:   articleID,
+				After:      snapshot(articleSnapshot(article)),
+			})
+
 			log.Println("Article created successfully")
 			http.Redirect(w, r, "/admin/articles/"+articleID+"/edit", http.StatusSeeOther)
 		}
 	}
 
-	pageTemplate := `
-	{{define "content"}}
-		<header class="row">
-			<h1 class="col">Create an Article</h1>
-			<div class="col-end">
-				<a class="btn" href="/admin/articles">Articles</a>
-			</div>
-		</header>
-
-		<form action="/admin/articles/create" method="POST" novalidate>
-			<div class="{{if index .ValidationErrors "Title"}}error{{end}}">
-				<label for="title">Article</label>
-				<input type="text" id="Title" name="title" value="{{.Article.Title}}">
-				<div>{{if index .ValidationErrors "Title"}}{{index .ValidationErrors "Title"}}{{end}}</div>
-			</div>
-			<button type="submit">Submit</button>
-		</form>
-	{{end}}
-	`
-
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("create_user").Parse(pageTemplate))
-
 	pageData := ArticleUpdateTemplate{
 		Title:            "Create a user",
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		Article:          article,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
 	}
 
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	err := app.Templates.Render(w, "admin/article_create", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -154,46 +136,16 @@ func (app *App) ListArticles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articlesTemplate := `
-        {{define "content"}}
-            
-			<header class="row">
-				<h1 class="col">Articles</h1>
-				<div class="col-end">
-					<a class="btn" href="/admin/articles/create">Create</a>
-				</div>
-			</header>
-
-			{{range .Articles}}
-				<div class="row rotate">
-					<div class="col-4"><a href="/admin/articles/{{.ID}}">{{.Title}}</a></div>
-					<div class="col">{{safeValue .Type}}</div>
-					<div class="col">{{safeValue .Format}}</div>
-					<div class="col-end">
-						<a href="/admin/articles/{{.ID}}"><i class="fas fa-eye"></i></a>
-						<a href="/admin/articles/{{.ID}}/edit"><i class="fas fa-edit"></i></a>
-						<a href="/admin/articles/{{.ID}}/delete"><i class="fas fa-trash"></i></a>
-					</div>
-				</div>
-			{{end}}
-        {{end}}
-    `
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("content").Parse(articlesTemplate))
-
 	pageData := ArticlesPageData{
-		Title:        "Articles",
-		Description:  types.TypeSqlNullString("A list of articles"),
-		Keywords:     types.TypeSqlNullString("articles, blog"),
-		Articles:     articles,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       "Articles",
+		Description: types.TypeSqlNullString("A list of articles"),
+		Keywords:    types.TypeSqlNullString("articles, blog"),
+		Articles:    articles,
+		Nonce:       secure.Nonce(r),
+		CSRF:        csrf.Token(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "admin/articles", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -212,39 +164,18 @@ func (app *App) ViewArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"mdToHTML": mdToHTML,
-	}
-
-	articleTemplate := `
-		{{define "content"}}
-			<header class="row">
-				<h1 class="col">{{.Title}}</h1>
-				<div class="col-end">
-					<a class="btn" href="/admin/articles/{{.ID}}/edit">Edit</a>
-					<a class="btn" href="/admin/articles/{{.ID}}/delete">Delete</a>
-				</div>
-			</header>
-			<div>
-				{{mdToHTML .Body}}
-			</div>
-		{{end}}
-    `
-
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(articleTemplate))
-
 	pageData := ArticlePageData{
-		ID:           article.ID,
-		Title:        article.Title,
-		Description:  article.Description,
-		Keywords:     article.Keywords,
-		Body:         article.Body.String,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		ID:          article.ID,
+		Title:       article.Title,
+		Description: article.Description,
+		Keywords:    article.Keywords,
+		Body:        article.Body.String,
+		Format:      article.Format.String,
+		Nonce:       secure.Nonce(r),
+		CSRF:        csrf.Token(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "admin/article", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -267,8 +198,10 @@ func (app *App) UpdateArticle(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == "POST" {
 		validate := validator.New()
+		validate.RegisterValidation("articleFormat", types.ArticleFormat)
 
 		publishedTime, _ := types.ParseSqlNullTime(r.FormValue("published"))
+		before := articleSnapshot(article)
 
 		article.ID = r.FormValue("id")
 		article.Title = r.FormValue("title")
@@ -294,6 +227,8 @@ func (app *App) UpdateArticle(w http.ResponseWriter, r *http.Request) {
 				switch tag {
 				case "required":
 					errorMessage = fmt.Sprintf("%s is required", fieldName)
+				case "articleFormat":
+					errorMessage = fmt.Sprintf("%s must be one of the listed formats", fieldName)
 				default:
 					errorMessage = fmt.Sprintf("%s is invalid", fieldName)
 				}
@@ -304,7 +239,7 @@ func (app *App) UpdateArticle(w http.ResponseWriter, r *http.Request) {
 
 			query := `
 				UPDATE articles
-				SET title = $1, description = $2, keywords = $3, body = $4, type = $5, format = $6, published = $7
+				SET title = $1, description = $2, keywords = $3, body = $4, type = $5, format = $6, published = $7, updated = now()
 				WHERE id = $8
 			`
 
@@ -324,77 +259,59 @@ func (app *App) UpdateArticle(w http.ResponseWriter, r *http.Request) {
 				log.Fatalf("commit transaction failed: %v", err)
 			}
 
-		}
-	}
+			app.Pages.Invalidate(article.ID)
+
+			if was, now := changes(before, articleSnapshot(article)); now != nil {
+				app.audit(r, types.AuditEvent{
+					Actor:      app.actor(r),
+					Action:     "article.updated",
+					TargetType: "article",
+					TargetID:   article.ID,
+					Before:     was,
+					After:      now,
+				})
+			}
 
-	pageTemplate := `
-	{{define "content"}}
-		<header class="row">
-			<h1 class="col">{{.Title}}</h1>
-			<div class="col-end">
-				<a class="btn" href="/admin/articles/{{.Article.ID}}">View</a>
-				<a class="btn" href="/admin/articles/{{.Article.ID}}/delete">Delete</a>
-			</div>
-		</header>
-		<form action="/admin/articles/{{.Article.ID}}/edit" method="POST">
-			<input type="hidden" name="id" value="{{.Article.ID}}">
-			<div class="{{if index .ValidationErrors "Title"}}error{{end}}">
-				<label for="title">Article</label>
-				<input type="text" id="Title" name="title" value="{{.Article.Title}}">
-				<div>{{if index .ValidationErrors "Title"}}{{index .ValidationErrors "Title"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Slug"}}error{{end}}">
-				<label for="slug">Slug</label>
-				<input type="text" id="Slug" name="slug" value="{{.Article.Slug}}">
-				<div>{{if index .ValidationErrors "Slug"}}{{index .ValidationErrors "Slug"}}{{end}}</div>
-			</div>
-			<div>
-				<label for="body">Article</label>
-				<textarea id="Body" name="body" rows="40">{{safeValue .Article.Body}}</textarea>
-			</div>
-			<div>
-				<label for="description">Description</label>
-				<textarea id="Description" name="description">{{safeValue .Article.Description}}</textarea>
-			</div>
-			<div>
-				<label for="keywords">Keywords</label>
-				<textarea id="Keywords" name="keywords">{{safeValue .Article.Keywords}}</textarea>
-			</div>
-			<div>
-				<label for="type">Type</label>
-				<input type="text" id="Type" name="type" value="{{safeValue .Article.Type}}">
-			</div>
-			<div>
-				<label for="format">Format</label>
-				<input type="text" id="Format" name="format" value="{{safeValue .Article.Format}}">
-			</div>
-			<div>
-				<label for="published">Published</label>
-				<input type="text" id="Published" name="published" value="{{safeValue .Article.Published}}">
-			</div>
-			<button type="submit">Submit</button>
-		</form>
-	{{end}}
-	`
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
+		}
 	}
 
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(pageTemplate))
-
 	pageData := ArticleUpdateTemplate{
 		Title:            "Update Article",
 		Description:      types.TypeSqlNullString("Register your account"),
 		Keywords:         types.TypeSqlNullString("resgistration"),
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		Article:          article,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		Formats:          render.Formats(),
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
+	}
+
+	err = app.Templates.Render(w, "admin/article_edit", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (app *App) ConfirmDeleteArticle(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	article, err := db.FetchArticleByID(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchArticleByID failed: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	pageData := ArticlePageData{
+		ID:    article.ID,
+		Title: article.Title,
+		Nonce: secure.Nonce(r),
+		CSRF:  csrf.Token(r),
+	}
+
+	err = app.Templates.Render(w, "admin/article_delete", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -405,12 +322,29 @@ func (app *App) DeleteArticle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := db.DeleteArticle(id)
+	article, err := db.FetchArticleByID(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchArticleByID failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.DeleteArticle(id)
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("DeleteArticleByID failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	app.Pages.Invalidate(id)
+
+	app.audit(r, types.AuditEvent{
+		Actor:      app.actor(r),
+		Action:     "article.deleted",
+		TargetType: "article",
+		TargetID:   id,
+		Before:     snapshot(articleSnapshot(article)),
+	})
+
 	http.Redirect(w, r, "/admin/articles", http.StatusSeeOther)
 }
@@ -0,0 +1,306 @@
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+)
+
+// auditPageSize is how many events the audit log shows per page.
+const auditPageSize = 50
+
+type AuditPageData struct {
+	Title     string
+	Events    []types.AuditEvent
+	Actions   []string
+	Filter    AuditFilterForm
+	PrevURL   string
+	NextURL   string
+	ExportURL string
+	Nonce     string
+	CSRF      string
+}
+
+// AuditFilterForm is the audit log's filter as typed into its form.
+type AuditFilterForm struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       string
+	To         string
+}
+
+// audit records event with the request's address. A failure is logged
+// rather than failing the request that caused it.
+func (app *App) audit(r *http.Request, event types.AuditEvent) {
+	db := db.DB{DB: app.DB}
+	event.IP = throttle.ClientIP(r)
+
+	_, err := db.CreateAuditEvent(event)
+	if err != nil {
+		log.Printf("Failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+// snapshot encodes v for an audit event's Before or After.
+func snapshot(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to encode audit snapshot: %v", err)
+		return nil
+	}
+	return b
+}
+
+// changes returns snapshots of only the fields that differ between before
+// and after, or nils when nothing changed.
+func changes(before, after map[string]interface{}) (json.RawMessage, json.RawMessage) {
+	was := make(map[string]interface{})
+	now := make(map[string]interface{})
+
+	for key, value := range before {
+		if !reflect.DeepEqual(value, after[key]) {
+			was[key] = value
+			now[key] = after[key]
+		}
+	}
+	for key, value := range after {
+		if _, ok := before[key]; !ok {
+			was[key] = nil
+			now[key] = value
+		}
+	}
+
+	if len(now) == 0 {
+		return nil, nil
+	}
+	return snapshot(was), snapshot(now)
+}
+
+// userSnapshot is what the audit log keeps of a user.
+func userSnapshot(user types.User) map[string]interface{} {
+	return map[string]interface{}{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+		"role":       user.Role,
+	}
+}
+
+// articleSnapshot is what the audit log keeps of an article. The body is
+// only fingerprinted, so edits show up without copying every revision.
+func articleSnapshot(article types.Article) map[string]interface{} {
+	sum := sha256.Sum256([]byte(article.Body.String))
+
+	var published interface{}
+	if article.Published.Valid {
+		published = article.Published.Time.UTC().Format(time.RFC3339)
+	}
+
+	return map[string]interface{}{
+		"title":       article.Title,
+		"slug":        article.Slug,
+		"description": article.Description.String,
+		"keywords":    article.Keywords.String,
+		"type":        article.Type.String,
+		"format":      article.Format.String,
+		"published":   published,
+		"body_sha256": hex.EncodeToString(sum[:8]),
+	}
+}
+
+// actor returns the email of the signed in user.
+func (app *App) actor(r *http.Request) string {
+	session, err := app.SessionStore.Get(r, "com-jasonsnider-go")
+	if err != nil {
+		return ""
+	}
+	email, _ := session.Values["user_email"].(string)
+	return email
+}
+
+// auditFilter reads the audit log's filter from the query string. Dates are
+// whole days, To included.
+func auditFilter(r *http.Request) (AuditFilterForm, types.AuditFilter) {
+	query := r.URL.Query()
+	form := AuditFilterForm{
+		Actor:      strings.TrimSpace(query.Get("actor")),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   strings.TrimSpace(query.Get("target_id")),
+		From:       query.Get("from"),
+		To:         query.Get("to"),
+	}
+
+	filter := types.AuditFilter{
+		Actor:      form.Actor,
+		Action:     form.Action,
+		TargetType: form.TargetType,
+		TargetID:   form.TargetID,
+	}
+
+	if from, err := time.Parse("2006-01-02", form.From); err == nil {
+		filter.From = from
+	} else {
+		form.From = ""
+	}
+	if to, err := time.Parse("2006-01-02", form.To); err == nil {
+		filter.To = to.AddDate(0, 0, 1)
+	} else {
+		form.To = ""
+	}
+
+	return form, filter
+}
+
+// query encodes the filter for links that keep it.
+func (f AuditFilterForm) query() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"actor":       f.Actor,
+		"action":      f.Action,
+		"target_type": f.TargetType,
+		"target_id":   f.TargetID,
+		"from":        f.From,
+		"to":          f.To,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+func (app *App) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	form, filter := auditFilter(r)
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// One more than a page says whether there is a next one
+	filter.Limit = auditPageSize + 1
+	filter.Offset = (page - 1) * auditPageSize
+
+	events, err := db.FetchAuditEvents(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchAuditEvents failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	actions, err := db.FetchAuditActions()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchAuditActions failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pageData := AuditPageData{
+		Title:     "Audit log",
+		Events:    events,
+		Actions:   actions,
+		Filter:    form,
+		ExportURL: "/admin/audit/export.csv?" + form.query().Encode(),
+		Nonce:     secure.Nonce(r),
+		CSRF:      csrf.Token(r),
+	}
+
+	link := func(page int) string {
+		values := form.query()
+		values.Set("page", strconv.Itoa(page))
+		return "/admin/audit?" + values.Encode()
+	}
+	if len(events) > auditPageSize {
+		pageData.Events = events[:auditPageSize]
+		pageData.NextURL = link(page + 1)
+	}
+	if page > 1 {
+		pageData.PrevURL = link(page - 1)
+	}
+
+	err = app.Templates.Render(w, "admin/audit", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// ExportAuditEvents downloads every event matching the audit log's filter
+// as CSV. The export is itself audited.
+func (app *App) ExportAuditEvents(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	form, filter := auditFilter(r)
+
+	events, err := db.FetchAuditEvents(filter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchAuditEvents failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:  app.actor(r),
+		Action: "audit.exported",
+		After:  snapshot(map[string]interface{}{"filter": form.query(), "events": len(events)}),
+	})
+
+	name := fmt.Sprintf("audit-%s.csv", time.Now().UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "no-store")
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor", "action", "target_type", "target_id", "ip", "before", "after"})
+	for _, event := range events {
+		out.Write(csvRow(event))
+	}
+	out.Flush()
+
+	if err := out.Error(); err != nil {
+		log.Printf("Failed to write audit export: %v", err)
+	}
+}
+
+// csvRow formats event for the export.
+func csvRow(event types.AuditEvent) []string {
+	row := []string{
+		event.ID,
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.Actor,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.IP,
+		string(event.Before),
+		string(event.After),
+	}
+
+	for i, cell := range row {
+		row[i] = csvSafe(cell)
+	}
+	return row
+}
+
+// csvSafe stops a spreadsheet from running a cell as a formula, since most
+// of what is exported was typed by users.
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
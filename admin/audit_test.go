@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	before := map[string]interface{}{"email": "ada@example.com", "role": "user", "published": nil}
+
+	tests := []struct {
+		name     string
+		after    map[string]interface{}
+		was, now string
+	}{
+		{"nothing", map[string]interface{}{"email": "ada@example.com", "role": "user", "published": nil}, "", ""},
+		{"one field", map[string]interface{}{"email": "ada@example.com", "role": "admin", "published": nil}, `{"role":"user"}`, `{"role":"admin"}`},
+		{"set", map[string]interface{}{"email": "ada@example.com", "role": "user", "published": "2024-01-02T00:00:00Z"}, `{"published":null}`, `{"published":"2024-01-02T00:00:00Z"}`},
+		{"added", map[string]interface{}{"email": "ada@example.com", "role": "user", "published": nil, "slug": "a"}, `{"slug":null}`, `{"slug":"a"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			was, now := changes(before, test.after)
+			if string(was) != test.was || string(now) != test.now {
+				t.Errorf("changes = %s, %s; want %s, %s", was, now, test.was, test.now)
+			}
+		})
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"", ""},
+		{"ada@example.com", "ada@example.com"},
+		{`{"role":"admin"}`, `{"role":"admin"}`},
+		{"=HYPERLINK(\"http://example.com\")", "'=HYPERLINK(\"http://example.com\")"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+	}
+
+	for _, test := range tests {
+		if got := csvSafe(test.cell); got != test.want {
+			t.Errorf("csvSafe(%q) = %q; want %q", test.cell, got, test.want)
+		}
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/admin/audit?actor=+ada@example.com+&action=user.updated&from=2024-03-01&to=2024-03-31&target_type=user", nil)
+	form, filter := auditFilter(r)
+
+	if filter.Actor != "ada@example.com" || filter.Action != "user.updated" || filter.TargetType != "user" {
+		t.Errorf("filter = %+v", filter)
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !filter.From.Equal(want) {
+		t.Errorf("From = %v; want %v", filter.From, want)
+	}
+	if want := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC); !filter.To.Equal(want) {
+		t.Errorf("To = %v; want %v, the end of the last day", filter.To, want)
+	}
+	if got := form.query().Encode(); got != "action=user.updated&actor=ada%40example.com&from=2024-03-01&target_type=user&to=2024-03-31" {
+		t.Errorf("query = %s", got)
+	}
+
+	form, filter = auditFilter(httptest.NewRequest("GET", "/admin/audit?from=yesterday", nil))
+	if !filter.From.IsZero() || form.From != "" {
+		t.Errorf("a bad date was kept: form %+v, filter %+v", form, filter)
+	}
+}
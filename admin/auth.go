@@ -2,17 +2,21 @@ package admin
 
 import (
 	"fmt"
-	"html/template"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/sessions"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
 )
 
 type AuthTemplate struct {
@@ -22,15 +26,18 @@ type AuthTemplate struct {
 	Body             string
 	ValidationErrors map[string]string
 	Auth             types.Auth
-	BustCssCache     string
-	BustJsCache      string
+	Notice           string
+	Passkeys         bool
+	SSOName          string
+	Nonce            string
+	CSRF             string
 }
 
 func (app *App) Authenticate(w http.ResponseWriter, r *http.Request) {
 
-	db := db.DB{DB: app.DB}
 	auth := types.Auth{}
 	validationErrors := make(map[string]string)
+	status := http.StatusOK
 
 	if r.Method == "POST" {
 		validate := validator.New()
@@ -59,98 +66,255 @@ func (app *App) Authenticate(w http.ResponseWriter, r *http.Request) {
 
 				validationErrors[fieldName] = errorMessage
 			}
+			status = http.StatusBadRequest
 		} else {
 
-			user, err := db.FetchAuth(auth.Email)
+			ip := throttle.ClientIP(r)
 
-			if err == nil {
-
-				confirm := passwords.CheckPasswordHash(auth.Password, user.Hash)
-				if confirm {
+			block, err := app.Limiter.Check(ip, auth.Email)
+			if err != nil {
+				log.Printf("Login throttle check failed: %v", err)
+			}
 
-					sessionExpiryStr := os.Getenv("SESSION_EXPIRY")
-					sessionExpiry, err := strconv.Atoi(sessionExpiryStr)
-					log.Printf("Session expiry: %d", sessionExpiry)
-					if err != nil {
-						log.Printf("Invalid session expiry value: %v", err)
-						http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-						return
-					}
+			if block != nil {
+				log.Printf("Login refused for %s from %s: %v", auth.Email, ip, block)
+				validationErrors["Login"] = blockMessage(block)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(block.RetryAfter.Seconds()))))
+				status = http.StatusTooManyRequests
+			} else {
 
-					session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
-					session.Values["authenticated"] = true
-					session.Values["user_email"] = user.Email
-					session.Options.MaxAge = sessionExpiry
-					err = session.Save(r, w)
+				user, ok, err := app.checkCredentials(auth)
+				if err != nil {
+					log.Printf("Login failed for %s: %v", auth.Email, err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
 
-					if err != nil {
-						log.Printf("Failed to save session: %v", err)
-					} else {
-						log.Printf("Session saved for user: %s", user.Email)
-					}
+				if ok {
 
-					// Read back the session data
-					session, _ = app.SessionStore.Get(r, "com-jasonsnider-go")
-					authenticated := session.Values["authenticated"]
-					userEmail := session.Values["user_email"]
-					log.Printf("Session data - Authenticated: %v, User Email: %s", authenticated, userEmail)
+					if app.RequireVerifiedEmail && !user.EmailVerified {
+						app.sendVerification(r, types.User{ID: user.ID, Email: user.Email})
 
-					http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+						validationErrors["Login"] = "Verify your email address before logging in. We have emailed you a new link."
+						app.renderLogin(w, r, http.StatusForbidden, auth, validationErrors)
+						return
+					}
 
-					return
-				} else {
-					http.Error(w, fmt.Sprintf("Hash compare failed: %v", err), http.StatusInternalServerError)
+					app.completeLogin(w, r, user.Email, user.TwoFactor || user.Passkey || app.TwoFactorRoles[user.Role])
 					return
 				}
+
+				app.loginFailed(r, auth.Email, user.ID)
+				validationErrors["Login"] = "Invalid email or password"
+				status = http.StatusUnauthorized
 			}
 
 		}
 	}
 
-	pageTemplate := `
-	{{define "content"}}
-		<h1>Login</h1>
-		<form action="/admin/login" method="POST">
-			<div class="{{if index .ValidationErrors "Email"}}error{{end}}">
-				<label for="subject">Email</label>
-				<input type="email" id="email" name="email" value="{{.Auth.Email}}">
-				<div>{{if index .ValidationErrors "Email"}}{{index .ValidationErrors "Email"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Password"}}error{{end}}">
-				<label for="body">Password</label>
-				<input type="password" id="password" name="password" value="{{.Auth.Password}}">
-				<div>{{if index .ValidationErrors "Password"}}{{index .ValidationErrors "Password"}}{{end}}</div>
-			</div>
-			<button type="submit">Login</button>
-		</form>
-	{{end}}
-	`
-
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	tmpl := template.Must(template.New("layout").Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Funcs(funcMap).Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("registration").Parse(pageTemplate))
+	app.renderLogin(w, r, status, auth, validationErrors)
+}
+
+func (app *App) renderLogin(w http.ResponseWriter, r *http.Request, status int, auth types.Auth, validationErrors map[string]string) {
+	// Never send the password back
+	auth.Password = ""
 
 	pageData := AuthTemplate{
 		Title:            "Login",
 		Description:      "Login",
 		Keywords:         "login",
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		Auth:             auth,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		Notice:           loginNotice(r),
+		Passkeys:         app.WebAuthn != nil,
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
+	}
+
+	if app.SSO != nil {
+		pageData.SSOName = app.SSOName
 	}
 
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+
+	err := app.Templates.Render(w, "admin/login", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
 }
 
+// completeLogin sends a user who passed the first login step on to the
+// second one if they need it, or signs them in.
+func (app *App) completeLogin(w http.ResponseWriter, r *http.Request, email string, secondFactor bool) {
+	if secondFactor {
+		err := app.beginTwoFactor(w, r, email)
+		if err != nil {
+			log.Printf("Failed to save session: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, "/admin/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	err := app.startSession(w, r, email)
+	if err != nil {
+		log.Printf("Failed to save session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+}
+
+// startSession signs email in on this session once every login step has
+// passed.
+func (app *App) startSession(w http.ResponseWriter, r *http.Request, email string) error {
+	err := app.Limiter.Succeed(email)
+	if err != nil {
+		log.Printf("Failed to clear login failures: %v", err)
+	}
+
+	sessionExpiry, err := strconv.Atoi(os.Getenv("SESSION_EXPIRY"))
+	if err != nil {
+		return fmt.Errorf("invalid session expiry value: %v", err)
+	}
+
+	// The visitor's session, with its CSRF secret and whatever login steps
+	// it went through, is thrown away so an ID fixed before login cannot be
+	// used after it.
+	previous, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	if !previous.IsNew {
+		previous.Options.MaxAge = -1
+		err = previous.Save(r, w)
+		if err != nil {
+			return err
+		}
+	}
+
+	options := *app.SessionStore.Options
+	options.MaxAge = sessionExpiry
+
+	session := sessions.NewSession(app.SessionStore, "com-jasonsnider-go")
+	session.Options = &options
+	session.IsNew = true
+	session.Values["authenticated"] = true
+	session.Values["user_email"] = email
+
+	err = csrf.Renew(session)
+	if err != nil {
+		return err
+	}
+
+	err = session.Save(r, w)
+	if err != nil {
+		return err
+	}
+	log.Printf("Session saved for user: %s", email)
+
+	err = app.Sessions.Add(email, session.ID, r.UserAgent(), throttle.ClientIP(r), time.Duration(sessionExpiry)*time.Second)
+	if err != nil {
+		log.Printf("Failed to record session: %v", err)
+	}
+
+	return nil
+}
+
+// loginNotice explains why the visitor was sent to the login page.
+func loginNotice(r *http.Request) string {
+	if r.Method != "GET" {
+		return ""
+	}
+
+	switch {
+	case r.URL.Query().Get("reset") == "done":
+		return "Your password has been changed. Log in with the new one."
+	case r.URL.Query().Get("registered") == "verify":
+		return "Your account is ready. We have emailed you a link to verify your address."
+	case r.URL.Query().Get("verified") == "done":
+		return "Your email address is verified."
+	}
+	return ""
+}
+
+// checkCredentials reports whether auth holds a user's email and password.
+// An unknown email costs the same hash compare as a wrong password so the
+// time taken does not give away which emails have accounts. A matching
+// password with an out of date hash is rehashed.
+func (app *App) checkCredentials(auth types.Auth) (types.AuthUser, bool, error) {
+	users := db.DB{DB: app.DB}
+
+	user, err := users.FetchAuth(auth.Email)
+	if err == db.ErrNoCredentials {
+		passwords.DummyCompare(auth.Password)
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, err
+	}
+
+	ok := passwords.CheckPasswordHash(auth.Password, user.Hash)
+	if ok && passwords.NeedsRehash(user.Hash) {
+		app.rehash(user, auth.Password)
+	}
+
+	return user, ok, nil
+}
+
+// rehash upgrades a user's stored hash to the configured algorithm and
+// parameters while their password is at hand. A failure only means it is
+// tried again next login.
+func (app *App) rehash(user types.AuthUser, password string) {
+	users := db.DB{DB: app.DB}
+
+	hash, err := passwords.HashPassword(password)
+	if err != nil {
+		log.Printf("Failed to rehash password for %s: %v", user.Email, err)
+		return
+	}
+
+	err = users.RehashPassword(user.ID, user.Hash, hash)
+	if err != nil {
+		log.Printf("Failed to rehash password for %s: %v", user.Email, err)
+	}
+}
+
+// loginFailed counts a failed login and records an audit event when it
+// locks the account. userID is empty when no user has that email.
+func (app *App) loginFailed(r *http.Request, email, userID string) {
+	locked, err := app.Limiter.Fail(throttle.ClientIP(r), email)
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return
+	}
+
+	if locked {
+		log.Printf("Locked %s after %d failed logins", email, app.Limiter.LockAfter)
+		app.audit(r, types.AuditEvent{
+			Action:     "user.locked",
+			TargetType: "user",
+			TargetID:   userID,
+			After:      snapshot(map[string]interface{}{"email": email, "failures": app.Limiter.LockAfter, "locked_for": app.Limiter.LockFor.String()}),
+		})
+	}
+}
+
+// blockMessage tells the visitor why their login was refused.
+func blockMessage(block *throttle.Block) string {
+	wait := block.RetryAfter.Round(time.Second)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	if block.Locked {
+		return fmt.Sprintf("Too many failed logins; this account is locked for %s", wait)
+	}
+	return fmt.Sprintf("Too many failed logins; try again in %s", wait)
+}
+
 func (app *App) Logout(w http.ResponseWriter, r *http.Request) {
 
 	session, err := app.SessionStore.Get(r, "com-jasonsnider-go")
@@ -160,6 +324,13 @@ func (app *App) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if email, ok := session.Values["user_email"].(string); ok {
+		err = app.Sessions.Remove(email, session.ID)
+		if err != nil {
+			log.Printf("Failed to forget session: %v", err)
+		}
+	}
+
 	// This tells Redis to delete the session
 	session.Options.MaxAge = -1
 
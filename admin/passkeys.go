@@ -0,0 +1,398 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gorilla/mux"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passkeys"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+)
+
+// Session values holding a passkey ceremony between its two requests.
+const (
+	passkeyLoginKey        = "passkey_login"
+	passkeyRegistrationKey = "passkey_registration"
+)
+
+type PasskeysPageData struct {
+	Title       string
+	Description string
+	Keywords    string
+	Passkeys    []types.Passkey
+	Error       string
+	Nonce       string
+	CSRF        string
+}
+
+// passkeyUser loads an account with its passkeys for a ceremony.
+func (app *App) passkeyUser(id string) (*passkeys.User, error) {
+	db := db.DB{DB: app.DB}
+
+	user, err := db.FetchUserById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := db.FetchPasskeys(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, len(stored))
+	for i, passkey := range stored {
+		transports := make([]protocol.AuthenticatorTransport, len(passkey.Transports))
+		for j, transport := range passkey.Transports {
+			transports[j] = protocol.AuthenticatorTransport(transport)
+		}
+
+		credentials[i] = webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: passkey.SignCount,
+			},
+		}
+	}
+
+	return &passkeys.User{
+		ID:          user.ID,
+		Name:        user.Email,
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+		Credentials: credentials,
+	}, nil
+}
+
+// PasskeyLoginOptions starts a passkey login. After a password it asks for
+// one of that user's passkeys; from the login page any passkey will do.
+func (app *App) PasskeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	users := db.DB{DB: app.DB}
+
+	if app.WebAuthn == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	session, email := app.pendingLogin(r)
+
+	var user *passkeys.User
+	if email != "" {
+		account, err := users.FetchUserByEmail(email)
+		if err == nil {
+			user, err = app.passkeyUser(account.ID)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Passkey lookup failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	assertion, state, err := passkeys.BeginLogin(app.WebAuthn, user)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "No passkey is registered for this account"})
+		return
+	}
+
+	session.Values[passkeyLoginKey] = state
+	err = session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, assertion)
+}
+
+// PasskeyLogin finishes a passkey login and signs the user in. A passkey
+// counts as the second factor after a password, and as both factors on its
+// own since the authenticator verified the user.
+func (app *App) PasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	users := db.DB{DB: app.DB}
+
+	if app.WebAuthn == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	session, email := app.pendingLogin(r)
+	state, _ := session.Values[passkeyLoginKey].(string)
+
+	// Each challenge is answered once
+	delete(session.Values, passkeyLoginKey)
+	err := session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	block, err := app.Limiter.Check(throttle.ClientIP(r), email)
+	if err != nil {
+		log.Printf("Login throttle check failed: %v", err)
+	}
+	if block != nil {
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": blockMessage(block)})
+		return
+	}
+
+	user, credential, err := passkeys.FinishLogin(app.WebAuthn, state, r.Body, app.passkeyUser)
+	if errors.Is(err, passkeys.ErrCloned) {
+		app.audit(r, types.AuditEvent{
+			Action:     "user.passkey_cloned",
+			TargetType: "user",
+			TargetID:   user.ID,
+		})
+	}
+	if err != nil {
+		log.Printf("Passkey login failed: %v", err)
+
+		// The response may name the account when the login form did not
+		userID := ""
+		if user != nil {
+			email, userID = user.Name, user.ID
+		}
+		app.loginFailed(r, email, userID)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "That passkey was not accepted"})
+		return
+	}
+
+	err = users.UsePasskey(credential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+	if err != nil {
+		log.Printf("UsePasskey failed: %v", err)
+	}
+
+	if app.RequireVerifiedEmail {
+		account, err := users.FetchUserById(user.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if !account.EmailVerifiedAt.Valid {
+			app.sendVerification(r, account)
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "Verify your email address before logging in. We've sent you a new link."})
+			return
+		}
+	}
+
+	err = app.startSession(w, r, user.Name)
+	if err != nil {
+		log.Printf("Failed to save session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"redirect": "/admin/dashboard"})
+}
+
+// ListPasskeys shows the signed in user's passkeys, with a form to add one.
+func (app *App) ListPasskeys(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+
+	account, err := db.FetchUserByEmail(app.actor(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserByEmail failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	list, err := db.FetchPasskeys(account.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchPasskeys failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pageData := PasskeysPageData{
+		Title:    "Passkeys",
+		Passkeys: list,
+		Nonce:    secure.Nonce(r),
+		CSRF:     csrf.Token(r),
+	}
+
+	if app.WebAuthn == nil {
+		pageData.Error = "Passkeys need APP_URL to be set to the site's address."
+	}
+
+	err = app.Templates.Render(w, "admin/passkeys", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// PasskeyRegistrationOptions starts adding a passkey to the signed in
+// user's account.
+func (app *App) PasskeyRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+
+	if app.WebAuthn == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	account, err := db.FetchUserByEmail(app.actor(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserByEmail failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := app.passkeyUser(account.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Passkey lookup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	creation, state, err := passkeys.BeginRegistration(app.WebAuthn, user)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("BeginRegistration failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	session.Values[passkeyRegistrationKey] = state
+	err = session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, creation)
+}
+
+// CreatePasskey stores the passkey the browser just created. Its name comes
+// from the name query parameter since the body is the authenticator's
+// response.
+func (app *App) CreatePasskey(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+
+	if app.WebAuthn == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	email := app.actor(r)
+	account, err := db.FetchUserByEmail(email)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserByEmail failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := app.passkeyUser(account.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Passkey lookup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	state, _ := session.Values[passkeyRegistrationKey].(string)
+	delete(session.Values, passkeyRegistrationKey)
+	err = session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	credential, err := passkeys.FinishRegistration(app.WebAuthn, user, state, r.Body)
+	if err != nil {
+		log.Printf("Passkey registration failed: %v", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "The passkey could not be added"})
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 100 {
+		name = name[:100]
+	}
+
+	transports := make([]string, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	passkey := types.Passkey{
+		UserID:          account.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+
+	passkey.ID, err = db.CreatePasskey(passkey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("CreatePasskey failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      email,
+		Action:     "user.passkey_added",
+		TargetType: "user",
+		TargetID:   account.ID,
+		After:      snapshot(passkey),
+	})
+
+	writeJSON(w, http.StatusOK, map[string]string{"redirect": "/admin/account/passkeys"})
+}
+
+// DeletePasskey removes one of the signed in user's passkeys.
+func (app *App) DeletePasskey(w http.ResponseWriter, r *http.Request) {
+	users := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	email := app.actor(r)
+	account, err := users.FetchUserByEmail(email)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserByEmail failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = users.DeletePasskey(account.ID, id)
+	if err == db.ErrPasskeyNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DeletePasskey failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      email,
+		Action:     "user.passkey_removed",
+		TargetType: "user",
+		TargetID:   account.ID,
+		Before:     snapshot(map[string]string{"id": id}),
+	})
+
+	http.Redirect(w, r, "/admin/account/passkeys", http.StatusSeeOther)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
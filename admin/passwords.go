@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/inflection"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+// resetPurpose is the token purpose for password reset links.
+const resetPurpose = "password-reset"
+
+// resetInterval is how long a user waits between reset emails.
+const resetInterval = time.Minute
+
+type PasswordResetTemplate struct {
+	Title            string
+	Description      string
+	Keywords         string
+	ValidationErrors map[string]string
+	Email            string
+	Token            string
+	Sent             bool
+	Error            string
+	Nonce            string
+	CSRF             string
+}
+
+// ForgotPassword emails a reset link to the address given. The response is
+// the same whether or not an account uses it.
+func (app *App) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	users := db.DB{DB: app.DB}
+	pageData := PasswordResetTemplate{ValidationErrors: make(map[string]string)}
+	status := http.StatusOK
+
+	if r.Method == "POST" {
+		pageData.Email = r.FormValue("email")
+
+		err := validator.New().Var(pageData.Email, "required,email")
+		if err != nil {
+			pageData.ValidationErrors["Email"] = "Email must be a valid email address"
+			status = http.StatusBadRequest
+		} else {
+			user, err := users.FetchUserByEmail(pageData.Email)
+			if err == nil {
+				app.sendResetLink(r, user)
+			} else if err != db.ErrUserNotFound {
+				log.Printf("FetchUserByEmail failed: %v", err)
+			}
+
+			pageData.Sent = true
+		}
+	}
+
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+
+	pageData.Title = "Forgot your password?"
+	app.renderPasswordReset(w, r, "admin/forgot_password", pageData)
+}
+
+// sendResetLink stores a reset link for user and emails it, unless one was
+// sent a moment ago. Failures are only logged so the visitor cannot tell.
+func (app *App) sendResetLink(r *http.Request, user types.User) {
+	db := db.DB{DB: app.DB}
+
+	recent, err := db.RecentPasswordReset(user.ID, time.Now().Add(-resetInterval))
+	if err != nil {
+		log.Printf("RecentPasswordReset failed: %v", err)
+		return
+	}
+	if recent {
+		log.Printf("Skipped password reset for %s; one was sent in the last %s", user.Email, resetInterval)
+		return
+	}
+
+	if app.BaseURL == "" {
+		log.Printf("Skipped password reset for %s; APP_URL is not set", user.Email)
+		return
+	}
+
+	token, tokenHash, err := app.Tokens.Generate(resetPurpose)
+	if err != nil {
+		log.Printf("Token generation failed: %v", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(app.ResetTTL)
+	_, err = db.CreatePasswordReset(user.ID, user.Email, tokenHash, expiresAt)
+	if err != nil {
+		log.Printf("CreatePasswordReset failed: %v", err)
+		return
+	}
+
+	link := app.link("/admin/reset-password?token=" + token)
+	body := fmt.Sprintf("Someone asked to reset the password for %s.\n\n"+
+		"Follow this link to choose a new one before %s:\n\n%s\n\n"+
+		"If it was not you, ignore this email and your password stays the same.\n",
+		user.Email, expiresAt.Format("Jan 2, 2006 15:04 MST"), link)
+
+	// Sent in the background so the response takes as long as for an
+	// unknown address
+	go func() {
+		err := app.Mail.Send(user.Email, "Reset your password", body)
+		if err != nil {
+			log.Printf("Failed to send password reset to %s: %v", user.Email, err)
+		}
+	}()
+}
+
+// ResetPassword sets a new password through an emailed link and ends every
+// session the user has.
+func (app *App) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	resets := db.DB{DB: app.DB}
+	pageData := PasswordResetTemplate{Title: "Choose a new password", ValidationErrors: make(map[string]string)}
+
+	pageData.Token = r.FormValue("token")
+	user, tokenHash, err := app.pendingReset(pageData.Token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchPendingPasswordReset failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if user == nil {
+		w.WriteHeader(http.StatusForbidden)
+		pageData.Error = "This reset link is invalid or has expired."
+		app.renderPasswordReset(w, r, "admin/reset_password", pageData)
+		return
+	}
+
+	pageData.Email = user.Email
+
+	if r.Method == "POST" {
+		reset := types.ResetPassword{
+			Password:        r.FormValue("password"),
+			ConfirmPassword: r.FormValue("confirm_password"),
+			Email:           user.Email,
+			FirstName:       user.FirstName,
+			LastName:        user.LastName,
+		}
+
+		validate := validator.New()
+		var passwordErr error
+		validate.RegisterValidation("password", app.Passwords.Validator(&passwordErr))
+
+		err := validate.Struct(reset)
+
+		if err != nil {
+			for _, err := range err.(validator.ValidationErrors) {
+				fieldName := err.Field()
+				fieldNameHuman := inflection.Humanize(fieldName)
+				tag := err.Tag()
+
+				var errorMessage string
+				switch tag {
+				case "required":
+					errorMessage = fmt.Sprintf("%s is required", fieldNameHuman)
+				case "min":
+					errorMessage = fmt.Sprintf("%s must be at least %s characters long", fieldNameHuman, err.Param())
+				case "eqfield":
+					errorMessage = fmt.Sprintf("%s must match %s", fieldNameHuman, err.Param())
+				case "password":
+					errorMessage = fmt.Sprintf("%s %v", fieldNameHuman, passwordErr)
+				default:
+					errorMessage = fmt.Sprintf("%s is invalid", fieldNameHuman)
+				}
+
+				pageData.ValidationErrors[fieldName] = errorMessage
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			user, err := resets.ResetPassword(tokenHash, reset.Password)
+			if errors.Is(err, db.ErrResetInvalid) {
+				// Another submit of the same link got there first
+				w.WriteHeader(http.StatusForbidden)
+				pageData.Error = "This reset link is invalid or has expired."
+				app.renderPasswordReset(w, r, "admin/reset_password", pageData)
+				return
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("ResetPassword failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			err = app.Sessions.EndAll(user.Email)
+			if err != nil {
+				log.Printf("Failed to end sessions for %s: %v", user.Email, err)
+			}
+
+			err = app.Limiter.Unlock(user.Email)
+			if err != nil {
+				log.Printf("Failed to clear login failures for %s: %v", user.Email, err)
+			}
+
+			app.audit(r, types.AuditEvent{
+				Actor:      user.Email,
+				Action:     "user.password_reset",
+				TargetType: "user",
+				TargetID:   user.ID,
+			})
+
+			http.Redirect(w, r, "/admin/login?reset=done", http.StatusSeeOther)
+			return
+		}
+	}
+
+	app.renderPasswordReset(w, r, "admin/reset_password", pageData)
+}
+
+func (app *App) renderPasswordReset(w http.ResponseWriter, r *http.Request, name string, pageData PasswordResetTemplate) {
+	pageData.Description = pageData.Title
+	pageData.Keywords = "password"
+	pageData.Nonce = secure.Nonce(r)
+	pageData.CSRF = csrf.Token(r)
+
+	err := app.Templates.Render(w, name, pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// pendingReset returns the user a reset token was issued for and the
+// token's hash, or nil if the token cannot be used.
+func (app *App) pendingReset(token string) (*types.User, string, error) {
+	tokenHash, ok := app.Tokens.Verify(resetPurpose, token)
+	if !ok {
+		return nil, "", nil
+	}
+
+	resets := db.DB{DB: app.DB}
+	user, err := resets.FetchPendingPasswordReset(tokenHash)
+	if err == db.ErrResetInvalid {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &user, tokenHash, nil
+}
@@ -2,31 +2,73 @@ package admin
 
 import (
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/inflection"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+// Registration modes, set by REGISTRATION. Closed turns registration off,
+// invite lets only the holders of an invitation register and open lets
+// anyone register as a user.
+const (
+	RegistrationClosed = "closed"
+	RegistrationInvite = "invite"
+	RegistrationOpen   = "open"
 )
 
 func (app *App) RegisterUser(w http.ResponseWriter, r *http.Request) {
 
+	if app.Registration != RegistrationInvite && app.Registration != RegistrationOpen {
+		http.NotFound(w, r)
+		return
+	}
+
 	db := db.DB{DB: app.DB}
 	user := types.RegisterUser{}
 	validationErrors := make(map[string]string)
 
+	token := r.FormValue("token")
+	invitation, tokenHash, err := app.pendingInvitation(token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchPendingInvitation failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if invitation == nil && (token != "" || app.Registration == RegistrationInvite) {
+		message := "Registration is by invitation only."
+		if token != "" {
+			message = "This invitation is invalid or has expired."
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+		app.renderRegistration(w, r, UserRegistrationTemplate{Title: "Register your account", Error: message})
+		return
+	}
+
+	if invitation != nil {
+		user.Email = invitation.Email
+	}
+
 	if r.Method == "POST" {
 		validate := validator.New()
 		validate.RegisterValidation("uniqueEmail", db.UniqueEmail)
+		var passwordErr error
+		validate.RegisterValidation("password", app.Passwords.Validator(&passwordErr))
+
+		if invitation == nil {
+			user.Email = r.FormValue("email")
+		}
 
 		user = types.RegisterUser{
 			FirstName:       r.FormValue("first_name"),
 			LastName:        r.FormValue("last_name"),
-			Email:           r.FormValue("email"),
+			Email:           user.Email,
 			Password:        r.FormValue("password"),
 			ConfirmPassword: r.FormValue("confirm_password"),
 		}
@@ -51,74 +93,100 @@ func (app *App) RegisterUser(w http.ResponseWriter, r *http.Request) {
 					errorMessage = fmt.Sprintf("%s must be at least %s characters long", fieldNameHuman, err.Param())
 				case "eqfield":
 					errorMessage = fmt.Sprintf("%s must match %s", fieldNameHuman, err.Param())
+				case "password":
+					errorMessage = fmt.Sprintf("%s %v", fieldNameHuman, passwordErr)
 				default:
 					errorMessage = fmt.Sprintf("%s is invalid", fieldNameHuman)
 				}
 
 				validationErrors[fieldName] = errorMessage
 			}
+		} else if invitation != nil {
+			userID, err := db.AcceptInvitation(tokenHash, user)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("AcceptInvitation failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			app.audit(r, types.AuditEvent{
+				Actor:      user.Email,
+				Action:     "invitation.accepted",
+				TargetType: "user",
+				TargetID:   userID,
+				After:      snapshot(map[string]interface{}{"email": invitation.Email, "role": invitation.Role, "invitation": invitation.ID}),
+			})
+
+			log.Println("User registered successfully")
+			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+			return
 		} else {
-			// Register the user
-			err = db.RegisterUser(user)
+			userID, err := db.RegisterUser(user)
 			if err != nil {
-				log.Fatalf("failed to register user: %v", err)
+				http.Error(w, fmt.Sprintf("RegisterUser failed: %v", err), http.StatusInternalServerError)
+				return
 			}
 
+			app.audit(r, types.AuditEvent{
+				Actor:      user.Email,
+				Action:     "user.registered",
+				TargetType: "user",
+				TargetID:   userID,
+				After:      snapshot(map[string]interface{}{"email": user.Email, "first_name": user.FirstName, "last_name": user.LastName}),
+			})
+
+			app.sendVerification(r, types.User{ID: userID, Email: user.Email})
+
 			log.Println("User registered successfully")
+			http.Redirect(w, r, "/admin/login?registered=verify", http.StatusSeeOther)
+			return
 		}
 	}
 
-	pageTemplate := `
-	{{define "content"}}
-		<h1>Register</h1>
-		<form action="/admin/register" method="POST" novalidate>
-			<div class="{{if index .ValidationErrors "FirstName"}}error{{end}}">
-				<label for="first_name">First Name</label>
-				<input type="text" id="FirstName" name="first_name" value="{{.User.FirstName}}">
-				<div>{{if index .ValidationErrors "FirstName"}}{{index .ValidationErrors "FirstName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "LastName"}}error{{end}}">
-				<label for="last_name">Last Name</label>
-				<input type="text" id="LastName" name="last_name" value="{{.User.LastName}}">
-				<div>{{if index .ValidationErrors "LastName"}}{{index .ValidationErrors "LastName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Email"}}error{{end}}">
-				<label for="subject">Email</label>
-				<input type="email" id="email" name="email" value="{{.User.Email}}">
-				<div>{{if index .ValidationErrors "Email"}}{{index .ValidationErrors "Email"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Password"}}error{{end}}">
-				<label for="body">Password</label>
-				<input type="password" id="password" name="password" value="{{.User.Password}}">
-				<div>{{if index .ValidationErrors "Password"}}{{index .ValidationErrors "Password"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "ConfirmPassword"}}error{{end}}">
-				<label for="confirm_password">Confirm Password</label>
-				<input type="password" id="confirm_password" name="confirm_password" value="{{.User.ConfirmPassword}}">
-				<div>{{if index .ValidationErrors "ConfirmPassword"}}{{index .ValidationErrors "ConfirmPassword"}}{{end}}</div>
-			</div>
-			<button type="submit">Register</button>
-		</form>
-	{{end}}
-	`
-
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("registration").Parse(pageTemplate))
-
-	pageData := UserRegistrationTemplate{
+	// Never send the passwords back
+	user.Password = ""
+	user.ConfirmPassword = ""
+
+	app.renderRegistration(w, r, UserRegistrationTemplate{
 		Title:            "Register your account",
-		Description:      "Register your account",
-		Keywords:         "resgistration",
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		User:             user,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
-	}
+		Token:            token,
+		Invited:          invitation != nil,
+	})
+}
 
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+func (app *App) renderRegistration(w http.ResponseWriter, r *http.Request, pageData UserRegistrationTemplate) {
+	pageData.Description = "Register your account"
+	pageData.Keywords = "registration"
+	pageData.Nonce = secure.Nonce(r)
+	pageData.CSRF = csrf.Token(r)
+
+	err := app.Templates.Render(w, "admin/register", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
 }
+
+// pendingInvitation returns the invitation token was issued for and the
+// token's hash, or nil if there is no token or it cannot be used.
+func (app *App) pendingInvitation(token string) (*types.Invitation, string, error) {
+	if token == "" {
+		return nil, "", nil
+	}
+
+	tokenHash, ok := app.Tokens.Verify(invitationPurpose, token)
+	if !ok {
+		return nil, "", nil
+	}
+
+	invitations := db.DB{DB: app.DB}
+	invitation, err := invitations.FetchPendingInvitation(tokenHash)
+	if err == db.ErrInvitationInvalid {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &invitation, tokenHash, nil
+}
@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/auth"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+// SessionView is a session as shown on a page.
+type SessionView struct {
+	auth.Session
+	Device  string
+	Current bool
+}
+
+type SessionsPageData struct {
+	Title       string
+	Description string
+	Keywords    string
+	Sessions    []SessionView
+	Nonce       string
+	CSRF        string
+}
+
+// sessionViews lists the user's sessions, marking the one with ID current.
+func (app *App) sessionViews(email, current string) ([]SessionView, error) {
+	list, err := app.Sessions.List(email)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]SessionView, len(list))
+	for i, s := range list {
+		views[i] = SessionView{
+			Session: s,
+			Device:  auth.Device(s.UserAgent),
+			Current: s.Handle == auth.Handle(current),
+		}
+	}
+	return views, nil
+}
+
+// ListSessions shows where the signed in user is logged in.
+func (app *App) ListSessions(w http.ResponseWriter, r *http.Request) {
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+
+	views, err := app.sessionViews(app.actor(r), session.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("List sessions failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pageData := SessionsPageData{
+		Title:    "My sessions",
+		Sessions: views,
+		Nonce:    secure.Nonce(r),
+		CSRF:     csrf.Token(r),
+	}
+
+	err = app.Templates.Render(w, "admin/sessions", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// EndSession logs the signed in user out of one of their sessions. Ending
+// the current one is the same as logging out.
+func (app *App) EndSession(w http.ResponseWriter, r *http.Request) {
+	handle := mux.Vars(r)["handle"]
+	email := app.actor(r)
+
+	ended, err := app.Sessions.End(email, handle)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("End session failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !ended {
+		http.NotFound(w, r)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      email,
+		Action:     "user.session_ended",
+		TargetType: "user",
+		TargetID:   app.userID(email),
+		Before:     snapshot(map[string]interface{}{"session": handle}),
+	})
+
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	if auth.Handle(session.ID) == handle {
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/account/sessions", http.StatusSeeOther)
+}
+
+// EndOtherSessions logs the signed in user out everywhere but here.
+func (app *App) EndOtherSessions(w http.ResponseWriter, r *http.Request) {
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	email := app.actor(r)
+
+	ended, err := app.Sessions.EndOthers(email, session.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("End sessions failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if ended > 0 {
+		app.audit(r, types.AuditEvent{
+			Actor:      email,
+			Action:     "user.sessions_ended",
+			TargetType: "user",
+			TargetID:   app.userID(email),
+			Before:     snapshot(map[string]interface{}{"sessions": ended}),
+		})
+	}
+
+	http.Redirect(w, r, "/admin/account/sessions", http.StatusSeeOther)
+}
+
+// EndUserSessions lets an admin log a user out of every session.
+func (app *App) EndUserSessions(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	id := mux.Vars(r)["id"]
+
+	user, err := db.FetchUserById(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Sessions.EndAll(user.Email)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("End sessions failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      app.actor(r),
+		Action:     "user.logged_out",
+		TargetType: "user",
+		TargetID:   user.ID,
+		After:      snapshot(map[string]interface{}{"email": user.Email}),
+	})
+
+	http.Redirect(w, r, "/admin/users/"+user.ID, http.StatusSeeOther)
+}
+
+// endSessions logs a user out after an admin changed or removed their
+// account, so the change takes effect at once.
+func (app *App) endSessions(email string) {
+	err := app.Sessions.EndAll(email)
+	if err != nil {
+		log.Printf("Failed to end sessions for %s: %v", email, err)
+	}
+}
+
+// userID returns the ID of the account with email, or "" if it has gone.
+func (app *App) userID(email string) string {
+	db := db.DB{DB: app.DB}
+
+	user, err := db.FetchUserByEmail(email)
+	if err != nil {
+		return ""
+	}
+	return user.ID
+}
@@ -0,0 +1,196 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/sso"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+)
+
+// ssoFlowKey holds a single sign-on login between leaving for the identity
+// provider and coming back.
+const ssoFlowKey = "sso_flow"
+
+// SSOLogin sends the visitor to the identity provider to sign in.
+func (app *App) SSOLogin(w http.ResponseWriter, r *http.Request) {
+	if app.SSO == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	authURL, state, err := app.SSO.Begin()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("SSO failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	session.Values[ssoFlowKey] = state
+	err = session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// SSOCallback is where the identity provider sends the visitor back. The
+// account is found by the provider's ID for them, then by email, and
+// created if provisioning is on. Accounts with two factors still need the
+// second one.
+func (app *App) SSOCallback(w http.ResponseWriter, r *http.Request) {
+	users := db.DB{DB: app.DB}
+	validationErrors := make(map[string]string)
+
+	if app.SSO == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	state, _ := session.Values[ssoFlowKey].(string)
+	delete(session.Values, ssoFlowKey)
+	err := session.Save(r, w)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	block, err := app.Limiter.Check(throttle.ClientIP(r), "")
+	if err != nil {
+		log.Printf("Login throttle check failed: %v", err)
+	}
+	if block != nil {
+		validationErrors["Login"] = blockMessage(block)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(block.RetryAfter.Seconds()))))
+		app.renderLogin(w, r, http.StatusTooManyRequests, types.Auth{}, validationErrors)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	identity, err := app.SSO.Finish(ctx, state, r.URL.Query())
+	if err != nil {
+		log.Printf("SSO login failed: %v", err)
+		app.loginFailed(r, "", "")
+
+		validationErrors["Login"] = "Single sign-on failed, please try again"
+		if errors.Is(err, sso.ErrUnverifiedEmail) {
+			validationErrors["Login"] = "Your identity provider has not verified your email address"
+		}
+		app.renderLogin(w, r, http.StatusUnauthorized, types.Auth{}, validationErrors)
+		return
+	}
+
+	user, err := app.ssoUser(r, identity)
+	if err == db.ErrUserNotFound {
+		validationErrors["Login"] = fmt.Sprintf("There is no account for %s", identity.Email)
+		app.renderLogin(w, r, http.StatusForbidden, types.Auth{}, validationErrors)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("SSO failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if app.RequireVerifiedEmail && !user.EmailVerifiedAt.Valid && user.Email != identity.Email {
+		app.sendVerification(r, user)
+
+		validationErrors["Login"] = "Verify your email address before logging in. We have emailed you a new link."
+		app.renderLogin(w, r, http.StatusForbidden, types.Auth{}, validationErrors)
+		return
+	}
+
+	twoFactor, err := users.FetchTwoFactor(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchTwoFactor failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	passkeys, err := users.FetchPasskeys(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchPasskeys failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.completeLogin(w, r, user.Email, twoFactor.EnabledAt.Valid || len(passkeys) > 0 || app.TwoFactorRoles[user.Role])
+}
+
+// ssoUser returns the account for identity, linking or creating it on the
+// first single sign-on login. It returns db.ErrUserNotFound when there is
+// no account and provisioning is off.
+func (app *App) ssoUser(r *http.Request, identity sso.Identity) (types.User, error) {
+	users := db.DB{DB: app.DB}
+
+	user, err := users.FetchUserByIdentity(identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, users.LinkIdentity(user.ID, identity.Issuer, identity.Subject, identity.Email)
+	}
+	if err != db.ErrUserNotFound {
+		return user, err
+	}
+
+	user, err = users.FetchUserByEmail(identity.Email)
+	if err == nil {
+		err = users.LinkIdentity(user.ID, identity.Issuer, identity.Subject, identity.Email)
+		if err != nil {
+			return user, err
+		}
+
+		app.audit(r, types.AuditEvent{
+			Actor:      user.Email,
+			Action:     "user.identity_linked",
+			TargetType: "user",
+			TargetID:   user.ID,
+			After:      snapshot(map[string]string{"issuer": identity.Issuer, "subject": identity.Subject}),
+		})
+		return user, nil
+	}
+	if err != db.ErrUserNotFound || !app.SSOProvision {
+		return user, err
+	}
+
+	user = types.User{
+		FirstName: identity.GivenName,
+		LastName:  identity.FamilyName,
+		Email:     identity.Email,
+		Role:      app.SSODefaultRole,
+	}
+
+	// Not every provider splits the name up
+	if user.FirstName == "" && user.LastName == "" {
+		first, last, _ := strings.Cut(identity.Name, " ")
+		user.FirstName, user.LastName = first, last
+	}
+	if user.FirstName == "" {
+		user.FirstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	user.ID, err = users.ProvisionUser(user, identity.Issuer, identity.Subject)
+	if err != nil {
+		return user, err
+	}
+	user.EmailVerifiedAt.Valid = true
+
+	app.audit(r, types.AuditEvent{
+		Actor:      user.Email,
+		Action:     "user.provisioned",
+		TargetType: "user",
+		TargetID:   user.ID,
+		After:      snapshot(user),
+	})
+
+	return user, nil
+}
@@ -0,0 +1,463 @@
+package admin
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/sessions"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/twofactor"
+	"github.com/pquerna/otp"
+)
+
+// Session values for a login waiting on its second step, and for a key
+// being set up that has not been confirmed yet.
+const (
+	pendingUserKey  = "pending_user"
+	pendingSinceKey = "pending_since"
+	pendingKeyKey   = "pending_totp_key"
+)
+
+// pendingTTL is how long the second step waits after the password.
+const pendingTTL = 5 * time.Minute
+
+const recoveryCodeCount = 10
+
+type TwoFactorTemplate struct {
+	Title            string
+	Description      string
+	Keywords         string
+	ValidationErrors map[string]string
+	Enabled          bool
+	Required         bool
+	Passkey          bool
+	QRCode           template.URL
+	Secret           string
+	RecoveryCodes    []string
+	RemainingCodes   int
+	Nonce            string
+	CSRF             string
+}
+
+// beginTwoFactor remembers that email passed the password step, without
+// signing them in.
+func (app *App) beginTwoFactor(w http.ResponseWriter, r *http.Request, email string) error {
+	session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+	session.Values["authenticated"] = false
+	session.Values[pendingUserKey] = email
+	session.Values[pendingSinceKey] = time.Now().Unix()
+	return session.Save(r, w)
+}
+
+// pendingLogin returns the session and email of a login waiting on its
+// second step, or an empty email if there is none or it took too long.
+func (app *App) pendingLogin(r *http.Request) (*sessions.Session, string) {
+	session, err := app.SessionStore.Get(r, "com-jasonsnider-go")
+	if err != nil {
+		return session, ""
+	}
+
+	email, _ := session.Values[pendingUserKey].(string)
+	since, _ := session.Values[pendingSinceKey].(int64)
+	if email == "" || time.Since(time.Unix(since, 0)) > pendingTTL {
+		return session, ""
+	}
+
+	return session, email
+}
+
+// pendingKey returns the key being set up on this session, creating one for
+// email if there is none.
+func (app *App) pendingKey(w http.ResponseWriter, r *http.Request, session *sessions.Session, email string) (*otp.Key, error) {
+	if url, ok := session.Values[pendingKeyKey].(string); ok {
+		key, err := otp.NewKeyFromURL(url)
+		if err == nil && key.AccountName() == email {
+			return key, nil
+		}
+	}
+
+	key, err := twofactor.NewKey(app.Issuer, email)
+	if err != nil {
+		return nil, err
+	}
+
+	session.Values[pendingKeyKey] = key.String()
+	return key, session.Save(r, w)
+}
+
+// LoginTwoFactor is the second login step: a code from the user's
+// authenticator app, a recovery code or one of their passkeys. A user whose
+// role requires two factors and who has none sets up an app here first.
+func (app *App) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+
+	session, email := app.pendingLogin(r)
+	if email == "" {
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+
+	user, err := db.FetchAuth(email)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchAuth failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	twoFactor, err := db.FetchTwoFactor(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchTwoFactor failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pageData := TwoFactorTemplate{
+		Title:            "Two-factor authentication",
+		ValidationErrors: make(map[string]string),
+		Enabled:          twoFactor.EnabledAt.Valid,
+		Required:         app.TwoFactorRoles[user.Role],
+		Passkey:          user.Passkey && app.WebAuthn != nil,
+	}
+
+	var key *otp.Key
+	if !pageData.Enabled && !pageData.Passkey {
+		key, err = app.pendingKey(w, r, session, email)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Key generation failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	status := http.StatusOK
+
+	if r.Method == "POST" {
+		code := r.FormValue("code")
+
+		block, err := app.Limiter.Check(throttle.ClientIP(r), email)
+		if err != nil {
+			log.Printf("Login throttle check failed: %v", err)
+		}
+
+		switch {
+		case block != nil:
+			pageData.ValidationErrors["Code"] = blockMessage(block)
+			status = http.StatusTooManyRequests
+
+		case key != nil:
+			codes, ok := app.confirmKey(r, user.ID, email, key, code)
+			if ok {
+				err := app.startSession(w, r, email)
+				if err != nil {
+					log.Printf("Failed to save session: %v", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				app.renderRecoveryCodes(w, r, codes)
+				return
+			}
+
+			app.loginFailed(r, email, user.ID)
+			pageData.ValidationErrors["Code"] = "That code is not right; check the time on your device"
+			status = http.StatusUnauthorized
+
+		default:
+			if app.checkCode(r, email, user.ID, twoFactor, code) {
+				err := app.startSession(w, r, email)
+				if err != nil {
+					log.Printf("Failed to save session: %v", err)
+					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+
+				http.Redirect(w, r, "/admin/dashboard", http.StatusSeeOther)
+				return
+			}
+
+			app.loginFailed(r, email, user.ID)
+			pageData.ValidationErrors["Code"] = "Invalid code"
+			status = http.StatusUnauthorized
+		}
+	}
+
+	if key != nil {
+		pageData.QRCode, err = twofactor.QRCode(key)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("QR code failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pageData.Secret = key.Secret()
+	}
+
+	app.renderTwoFactor(w, r, status, "admin/login_2fa", pageData)
+}
+
+// checkCode reports whether code is the user's current TOTP code, or one of
+// their unused recovery codes, spending it either way.
+func (app *App) checkCode(r *http.Request, email, userID string, twoFactor types.TwoFactor, code string) bool {
+	db := db.DB{DB: app.DB}
+
+	// Codes for an empty secret are easy to work out, so only check a
+	// secret the user actually set up
+	counter, ok := twofactor.Validate(twoFactor.Secret, code, twoFactor.LastCounter, time.Now())
+	if ok && twoFactor.EnabledAt.Valid {
+		used, err := db.UseTOTPCounter(userID, counter)
+		if err != nil {
+			log.Printf("UseTOTPCounter failed: %v", err)
+			return false
+		}
+		return used
+	}
+
+	used, err := db.UseRecoveryCode(userID, twofactor.HashRecoveryCode(code))
+	if err != nil {
+		log.Printf("UseRecoveryCode failed: %v", err)
+		return false
+	}
+
+	if used {
+		app.audit(r, types.AuditEvent{
+			Actor:      email,
+			Action:     "user.recovery_code_used",
+			TargetType: "user",
+			TargetID:   userID,
+			After:      snapshot(map[string]interface{}{"remaining": twoFactor.RecoveryCodes - 1}),
+		})
+	}
+
+	return used
+}
+
+// confirmKey turns on two factors for a user once they enter a code from
+// the key they scanned, and returns their new recovery codes.
+func (app *App) confirmKey(r *http.Request, userID, email string, key *otp.Key, code string) ([]string, bool) {
+	db := db.DB{DB: app.DB}
+
+	counter, ok := twofactor.Validate(key.Secret(), code, 0, time.Now())
+	if !ok {
+		return nil, false
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Printf("Recovery code generation failed: %v", err)
+		return nil, false
+	}
+
+	err = db.EnableTwoFactor(userID, key.Secret(), hashes)
+	if err != nil {
+		log.Printf("EnableTwoFactor failed: %v", err)
+		return nil, false
+	}
+
+	_, err = db.UseTOTPCounter(userID, counter)
+	if err != nil {
+		log.Printf("UseTOTPCounter failed: %v", err)
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      email,
+		Action:     "user.2fa_enabled",
+		TargetType: "user",
+		TargetID:   userID,
+	})
+
+	return codes, true
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := twofactor.RecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = twofactor.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// AccountTwoFactor lets the signed in user set up, check or turn off two
+// factors for their own account.
+func (app *App) AccountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	email := app.actor(r)
+
+	user, err := db.FetchAuth(email)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchAuth failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	twoFactor, err := db.FetchTwoFactor(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchTwoFactor failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	pageData := TwoFactorTemplate{
+		Title:            "Two-factor authentication",
+		ValidationErrors: make(map[string]string),
+		Enabled:          twoFactor.EnabledAt.Valid,
+		Required:         app.TwoFactorRoles[user.Role],
+		RemainingCodes:   twoFactor.RecoveryCodes,
+	}
+	status := http.StatusOK
+
+	if !pageData.Enabled {
+		session, _ := app.SessionStore.Get(r, "com-jasonsnider-go")
+		key, err := app.pendingKey(w, r, session, email)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Key generation failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if r.Method == "POST" {
+			codes, ok := app.confirmKey(r, user.ID, email, key, r.FormValue("code"))
+			if ok {
+				delete(session.Values, pendingKeyKey)
+				err := session.Save(r, w)
+				if err != nil {
+					log.Printf("Failed to save session: %v", err)
+				}
+
+				app.renderRecoveryCodes(w, r, codes)
+				return
+			}
+
+			pageData.ValidationErrors["Code"] = "That code is not right; check the time on your device"
+			status = http.StatusBadRequest
+		}
+
+		pageData.QRCode, err = twofactor.QRCode(key)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("QR code failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pageData.Secret = key.Secret()
+	} else if r.Method == "POST" {
+		action := r.FormValue("action")
+
+		switch {
+		case !app.checkCode(r, email, user.ID, twoFactor, r.FormValue("code")):
+			pageData.ValidationErrors["Code"] = "Invalid code"
+			status = http.StatusBadRequest
+
+		case action == "disable" && pageData.Required:
+			pageData.ValidationErrors["Code"] = "Your role requires two-factor authentication"
+			status = http.StatusForbidden
+
+		case action == "disable":
+			err := db.DisableTwoFactor(user.ID)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("DisableTwoFactor failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			app.audit(r, types.AuditEvent{
+				Actor:      email,
+				Action:     "user.2fa_disabled",
+				TargetType: "user",
+				TargetID:   user.ID,
+			})
+
+			http.Redirect(w, r, "/admin/account/2fa", http.StatusSeeOther)
+			return
+
+		case action == "recovery-codes":
+			codes, hashes, err := newRecoveryCodes()
+			if err == nil {
+				err = db.ReplaceRecoveryCodes(user.ID, hashes)
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("ReplaceRecoveryCodes failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			app.audit(r, types.AuditEvent{
+				Actor:      email,
+				Action:     "user.recovery_codes_replaced",
+				TargetType: "user",
+				TargetID:   user.ID,
+			})
+
+			app.renderRecoveryCodes(w, r, codes)
+			return
+		}
+	}
+
+	app.renderTwoFactor(w, r, status, "admin/account_2fa", pageData)
+}
+
+// ResetTwoFactor lets an admin turn off two factors for a user who has lost
+// their device and recovery codes. Their passkeys are removed too.
+func (app *App) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := db.FetchUserById(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.DisableTwoFactor(user.ID)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DisableTwoFactor failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.DeletePasskeys(user.ID)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("DeletePasskeys failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      app.actor(r),
+		Action:     "user.2fa_reset",
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     snapshot(map[string]interface{}{"email": user.Email}),
+	})
+
+	http.Redirect(w, r, "/admin/users/"+user.ID, http.StatusSeeOther)
+}
+
+func (app *App) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	app.renderTwoFactor(w, r, http.StatusOK, "admin/recovery_codes", TwoFactorTemplate{
+		Title:         "Recovery codes",
+		Enabled:       true,
+		RecoveryCodes: codes,
+	})
+}
+
+func (app *App) renderTwoFactor(w http.ResponseWriter, r *http.Request, status int, name string, pageData TwoFactorTemplate) {
+	// Codes and keys must not be cached anywhere
+	w.Header().Set("Cache-Control", "no-store")
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+
+	pageData.Description = pageData.Title
+	pageData.Keywords = "two-factor authentication"
+	pageData.Nonce = secure.Nonce(r)
+	pageData.CSRF = csrf.Token(r)
+
+	err := app.Templates.Render(w, name, pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
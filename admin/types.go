@@ -9,6 +9,9 @@ type UserRegistrationTemplate struct {
 	Body             string
 	ValidationErrors map[string]string
 	User             types.RegisterUser
-	BustCssCache     string
-	BustJsCache      string
+	Token            string
+	Invited          bool
+	Error            string
+	Nonce            string
+	CSRF             string
 }
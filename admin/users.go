@@ -3,16 +3,17 @@ package admin
 import (
 	"context"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/inflection"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 )
 
 type UserCreateTemplate struct {
@@ -20,8 +21,8 @@ type UserCreateTemplate struct {
 	Body             string
 	ValidationErrors map[string]string
 	User             types.CreateUser
-	BustCssCache     string
-	BustJsCache      string
+	Nonce            string
+	CSRF             string
 }
 
 type UserUpdateTemplate struct {
@@ -29,15 +30,19 @@ type UserUpdateTemplate struct {
 	Body             string
 	ValidationErrors map[string]string
 	User             types.User
-	BustCssCache     string
-	BustJsCache      string
+	LockedFor        time.Duration
+	TwoFactor        types.TwoFactor
+	Passkeys         []types.Passkey
+	Sessions         []SessionView
+	Nonce            string
+	CSRF             string
 }
 
 type UsersPageData struct {
-	Title        string
-	Users        []types.User
-	BustCssCache string
-	BustJsCache  string
+	Title string
+	Users []types.User
+	Nonce string
+	CSRF  string
 }
 
 func (app *App) CreateUser(w http.ResponseWriter, r *http.Request) {
@@ -89,62 +94,28 @@ func (app *App) CreateUser(w http.ResponseWriter, r *http.Request) {
 				log.Fatalf("failed to create user: %v", err)
 			}
 
+			app.audit(r, types.AuditEvent{
+				Actor:      app.actor(r),
+				Action:     "user.created",
+				TargetType: "user",
+				TargetID:   userID,
+				After:      snapshot(userSnapshot(user)),
+			})
+
 			log.Println("User created successfully")
 			http.Redirect(w, r, "/admin/users/"+userID, http.StatusSeeOther)
 		}
 	}
 
-	pageTemplate := `
-	{{define "content"}}
-		<header class="row">
-			<h1 class="col">Create a User</h1>
-			<div class="col-end">
-				<a class="btn" href="/admin/users">Users</a>
-			</div>
-		</header>
-
-
-		<form action="/admin/users/create" method="POST" novalidate>
-			<div class="{{if index .ValidationErrors "FirstName"}}error{{end}}">
-				<label for="first_name">First Name</label>
-				<input type="text" id="FirstName" name="first_name" value="{{.User.FirstName}}">
-				<div>{{if index .ValidationErrors "FirstName"}}{{index .ValidationErrors "FirstName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "LastName"}}error{{end}}">
-				<label for="last_name">Last Name</label>
-				<input type="text" id="LastName" name="last_name" value="{{.User.LastName}}">
-				<div>{{if index .ValidationErrors "LastName"}}{{index .ValidationErrors "LastName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Email"}}error{{end}}">
-				<label for="subject">Email</label>
-				<input type="email" id="email" name="email" value="{{.User.Email}}">
-				<div>{{if index .ValidationErrors "Email"}}{{index .ValidationErrors "Email"}}{{end}}</div>
-			</div>
-			<div>
-				<label for="role">Role</label>
-				<select id="role" name="role">
-					<option value="admin" {{if eq .User.Role "admin"}} selected {{end}}>admin</option>
-					<option value="user" {{if eq .User.Role "user"}} selected {{end}}>user</option>
-				</select>
-			</div>
-			<button type="submit">Submit</button>
-		</form>
-	{{end}}
-	`
-
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("create_user").Parse(pageTemplate))
-
 	pageData := UserUpdateTemplate{
 		Title:            "Create a user",
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		User:             user,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
 	}
 
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	err := app.Templates.Render(w, "admin/user_create", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -159,40 +130,14 @@ func (app *App) ListUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	articlesTemplate := `
-        {{define "content"}}
-			<header class="row">
-				<h1 class="col">Users</h1>
-				<div class="col-end">
-					<a class="btn" href="/admin/users/create">Create</a>
-				</div>
-			</header>
-
-			{{range .Users}}
-				<div class="row rotate">
-					<div class="col"><a href="/admin/users/{{.ID}}">{{.LastName}}, {{.FirstName}}</a></div>
-					<div class="col">{{.Email}}</div>
-					<div class="col">{{.Role}}</div>
-					<div class="col-end">
-						<a href="/admin/users/{{.ID}}"><i class="fas fa-eye"></i></a>
-						<a href="/admin/users/{{.ID}}/edit"><i class="fas fa-edit"></i></a>
-						<a href="/admin/users/{{.ID}}/delete"><i class="fas fa-trash"></i></a>
-					</div>
-				</div>
-			{{end}}
-        {{end}}
-    `
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("content").Parse(articlesTemplate))
-
 	pageData := UsersPageData{
-		Title:        "Users",
-		Users:        users,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title: "Users",
+		Users: users,
+		Nonce: secure.Nonce(r),
+		CSRF:  csrf.Token(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "admin/users", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -213,33 +158,41 @@ func (app *App) ViewUser(w http.ResponseWriter, r *http.Request) {
 
 	validationErrors := make(map[string]string)
 
-	pageTemplate := `
-	{{define "content"}}
-		<header class="row">
-			<h1>{{.User.LastName}}, {{.User.FirstName}}</h1>
-			<div class="col-end">
-				<a class="btn" href="/admin/users/{{.User.ID}}/edit">Edit</a>
-				<a class="btn" href="/admin/users/{{.User.ID}}/delete">Delete</a>
-			</div>
-		</header>
-		<div>{{.User.Email}}</div>
-		<div>{{.User.Role}}</div>
-	{{end}}
-	`
-
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("update_user").Parse(pageTemplate))
+	lockedFor, err := app.Limiter.Locked(user.Email)
+	if err != nil {
+		log.Printf("Failed to read lock for %s: %v", user.Email, err)
+	}
+
+	twoFactor, err := db.FetchTwoFactor(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchTwoFactor failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	passkeys, err := db.FetchPasskeys(user.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchPasskeys failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sessions, err := app.sessionViews(user.Email, "")
+	if err != nil {
+		log.Printf("Failed to list sessions for %s: %v", user.Email, err)
+	}
 
 	pageData := UserUpdateTemplate{
 		Title:            user.LastName + ", " + user.FirstName,
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		User:             user,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		LockedFor:        lockedFor.Round(time.Second),
+		TwoFactor:        twoFactor,
+		Passkeys:         passkeys,
+		Sessions:         sessions,
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "admin/user", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -265,6 +218,10 @@ func (app *App) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		validate := validator.New()
 		validate.RegisterValidation("uniqueEmail", db.UniqueEmail)
 
+		previousEmail := user.Email
+		previousRole := user.Role
+		before := userSnapshot(user)
+
 		user.ID = r.FormValue("id")
 		user.FirstName = r.FormValue("first_name")
 		user.LastName = r.FormValue("last_name")
@@ -299,9 +256,11 @@ func (app *App) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 
+			// A new address needs verifying again
 			query := `
 				UPDATE users
-				SET first_name = $1, last_name = $2, email = $3, role = $4
+				SET first_name = $1, last_name = $2, email = $3, role = $4,
+					email_verified_at = CASE WHEN email = $3 THEN email_verified_at ELSE NULL END
 				WHERE id = $5
 			`
 
@@ -316,65 +275,78 @@ func (app *App) UpdateUser(w http.ResponseWriter, r *http.Request) {
 				log.Fatalf("update failed: %v", err)
 			}
 
+			// Reset links went to the old address, which may no longer be theirs
+			if user.Email != previousEmail {
+				_, err = tx.Exec(context.Background(), "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL", user.ID)
+				if err != nil {
+					tx.Rollback(context.Background())
+					log.Fatalf("delete password resets failed: %v", err)
+				}
+			}
+
 			err = tx.Commit(context.Background())
 			if err != nil {
 				log.Fatalf("commit transaction failed: %v", err)
 			}
 
+			if was, now := changes(before, userSnapshot(user)); now != nil {
+				app.audit(r, types.AuditEvent{
+					Actor:      app.actor(r),
+					Action:     "user.updated",
+					TargetType: "user",
+					TargetID:   user.ID,
+					Before:     was,
+					After:      now,
+				})
+			}
+
+			if user.Email != previousEmail {
+				user.EmailVerifiedAt.Valid = false
+				app.sendVerification(r, user)
+			}
+
+			// Sessions are tied to the old address and role, so they end
+			if user.Email != previousEmail || user.Role != previousRole {
+				app.endSessions(previousEmail)
+			}
+
 		}
 	}
 
-	pageTemplate := `
-	{{define "content"}}
-		<header class="row">
-			<h1>{{.User.LastName}}, {{.User.FirstName}}</h1>
-			<div class="col-end">
-				<a class="btn" href="/admin/users/{{.User.ID}}">View</a>
-				<a class="btn" href="/admin/users/{{.User.ID}}/delete">Delete</a>
-			</div>
-		</header>
-		<form action="/admin/users/{{.User.ID}}/edit" method="POST">
-			<input type="hidden" name="id" value="{{.User.ID}}">
-			<div class="{{if index .ValidationErrors "FirstName"}}error{{end}}">
-				<label for="first_name">First Name</label>
-				<input type="text" id="FirstName" name="first_name" value="{{.User.FirstName}}">
-				<div>{{if index .ValidationErrors "FirstName"}}{{index .ValidationErrors "FirstName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "LastName"}}error{{end}}">
-				<label for="last_name">Last Name</label>
-				<input type="text" id="LastName" name="last_name" value="{{.User.LastName}}">
-				<div>{{if index .ValidationErrors "LastName"}}{{index .ValidationErrors "LastName"}}{{end}}</div>
-			</div>
-			<div class="{{if index .ValidationErrors "Email"}}error{{end}}">
-				<label for="subject">Email</label>
-				<input type="email" id="email" name="email" value="{{.User.Email}}">
-				<div>{{if index .ValidationErrors "Email"}}{{index .ValidationErrors "Email"}}{{end}}</div>
-			</div>
-			<div>
-				<label for="role">Role</label>
-				<select id="role" name="role">
-					<option value="admin" {{if eq .User.Role "admin"}} selected {{end}}>admin</option>
-					<option value="user" {{if eq .User.Role "user"}} selected {{end}}>user</option>
-				</select>
-			</div>
-			<button type="submit">Submit</button>
-		</form>
-	{{end}}
-	`
-
-	tmpl := template.Must(template.New("layout").Parse(templates.AdminLayoutTemplate))
-	tmpl = template.Must(tmpl.New("update_user").Parse(pageTemplate))
-
 	pageData := UserUpdateTemplate{
 		Title:            "Update User",
-		Body:             pageTemplate,
 		ValidationErrors: validationErrors,
 		User:             user,
-		BustCssCache:     app.BustCssCache,
-		BustJsCache:      app.BustJsCache,
+		Nonce:            secure.Nonce(r),
+		CSRF:             csrf.Token(r),
+	}
+
+	err = app.Templates.Render(w, "admin/user_edit", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+func (app *App) ConfirmDeleteUser(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := db.FetchUserById(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	pageData := UserUpdateTemplate{
+		Title: user.LastName + ", " + user.FirstName,
+		User:  user,
+		Nonce: secure.Nonce(r),
+		CSRF:  csrf.Token(r),
+	}
+
+	err = app.Templates.Render(w, "admin/user_delete", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -385,12 +357,59 @@ func (app *App) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := db.DeleteUser(id)
+	user, err := db.FetchUserById(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = db.DeleteUser(id)
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("DeleteUser failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	app.endSessions(user.Email)
+
+	app.audit(r, types.AuditEvent{
+		Actor:      app.actor(r),
+		Action:     "user.deleted",
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     snapshot(userSnapshot(user)),
+	})
+
 	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
 }
+
+func (app *App) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := db.FetchUserById(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = app.Limiter.Unlock(user.Email)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Unlock failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.audit(r, types.AuditEvent{
+		Actor:      app.actor(r),
+		Action:     "user.unlocked",
+		TargetType: "user",
+		TargetID:   user.ID,
+		After:      snapshot(map[string]interface{}{"email": user.Email}),
+	})
+
+	http.Redirect(w, r, "/admin/users/"+user.ID, http.StatusSeeOther)
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+// verificationPurpose is the token purpose for email verification links.
+const verificationPurpose = "email-verification"
+
+// verificationInterval is how long a user waits between verification
+// emails.
+const verificationInterval = time.Minute
+
+type VerifyEmailTemplate struct {
+	Title       string
+	Description string
+	Keywords    string
+	Error       string
+	Nonce       string
+	CSRF        string
+}
+
+// sendVerification emails user a link verifying their current address,
+// unless one was sent a moment ago. Failures are only logged.
+func (app *App) sendVerification(r *http.Request, user types.User) {
+	db := db.DB{DB: app.DB}
+
+	recent, err := db.RecentEmailVerification(user.ID, time.Now().Add(-verificationInterval))
+	if err != nil {
+		log.Printf("RecentEmailVerification failed: %v", err)
+		return
+	}
+	if recent {
+		log.Printf("Skipped verification for %s; one was sent in the last %s", user.Email, verificationInterval)
+		return
+	}
+
+	if app.BaseURL == "" {
+		log.Printf("Skipped verification for %s; APP_URL is not set", user.Email)
+		return
+	}
+
+	token, tokenHash, err := app.Tokens.Generate(verificationPurpose)
+	if err != nil {
+		log.Printf("Token generation failed: %v", err)
+		return
+	}
+
+	expiresAt := time.Now().Add(app.VerificationTTL)
+	_, err = db.CreateEmailVerification(user.ID, user.Email, tokenHash, expiresAt)
+	if err != nil {
+		log.Printf("CreateEmailVerification failed: %v", err)
+		return
+	}
+
+	link := app.link("/admin/verify-email?token=" + token)
+	body := fmt.Sprintf("Follow this link before %s to confirm %s is your address:\n\n%s\n\n"+
+		"If you did not ask for this, ignore this email.\n",
+		expiresAt.Format("Jan 2, 2006 15:04 MST"), user.Email, link)
+
+	go func() {
+		err := app.Mail.Send(user.Email, "Verify your email address", body)
+		if err != nil {
+			log.Printf("Failed to send verification to %s: %v", user.Email, err)
+		}
+	}()
+}
+
+// VerifyEmail marks the address an emailed link was sent to as verified.
+func (app *App) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+
+	tokenHash, ok := app.Tokens.Verify(verificationPurpose, r.FormValue("token"))
+	if ok {
+		user, err := db.VerifyEmail(tokenHash)
+		if err == nil {
+			app.audit(r, types.AuditEvent{
+				Actor:      user.Email,
+				Action:     "user.email_verified",
+				TargetType: "user",
+				TargetID:   user.ID,
+				After:      snapshot(map[string]interface{}{"email": user.Email}),
+			})
+
+			http.Redirect(w, r, "/admin/login?verified=done", http.StatusSeeOther)
+			return
+		}
+
+		log.Printf("VerifyEmail failed: %v", err)
+	}
+
+	pageData := VerifyEmailTemplate{
+		Title:       "Verify your email address",
+		Description: "Verify your email address",
+		Keywords:    "email, verification",
+		Error:       "This verification link is invalid or has expired. Log in to have a new one sent.",
+		Nonce:       secure.Nonce(r),
+		CSRF:        csrf.Token(r),
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	err := app.Templates.Render(w, "admin/verify_email", pageData)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// SendVerification lets an admin send a user a new verification link.
+func (app *App) SendVerification(w http.ResponseWriter, r *http.Request) {
+	db := db.DB{DB: app.DB}
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := db.FetchUserById(id)
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("FetchUserById failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	app.sendVerification(r, user)
+
+	http.Redirect(w, r, "/admin/users/"+user.ID, http.StatusSeeOther)
+}
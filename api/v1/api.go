@@ -3,6 +3,7 @@ package api
 import (
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/compress"
 )
 
 type App struct {
@@ -13,6 +14,7 @@ func APIRouter(dbpool *pgxpool.Pool) *mux.Router {
 	app := &App{DB: dbpool}
 
 	router := mux.NewRouter()
+	router.Use(compress.Handler)
 	router.HandleFunc("/users", app.GetUsers).Methods("GET")
 	router.HandleFunc("/user/{id}", app.GetUser).Methods("GET")
 	router.HandleFunc("/users", app.CreateUser).Methods("POST")
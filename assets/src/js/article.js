@@ -1,7 +0,0 @@
-/* Highlight.js */
-window.onload = function() {
-  var aCodes = document.getElementsByTagName('pre');
-  for (var i=0; i < aCodes.length; i++) {
-      hljs.highlightBlock(aCodes[i]);
-  }
-};
@@ -6,39 +6,6 @@ var rename = require('gulp-rename');
 var concat = require('gulp-concat');
 var merge = require('merge-stream');
 var scss = require('gulp-sass');
-var fs = require('fs');
-
-function version(){
-  var now = new Date(),
-    Y = now.getFullYear(),
-    m = now.getMonth()+1,
-    d = now.getDate(),
-    H = now.getHours(),
-    i = now.getMinutes(),
-    s = now.getSeconds();
-
-    if(H < 10) {
-        H = '0' + H;
-    }
-
-    if(i < 10) {
-        i = '0' + i;
-    }
-
-    if(s < 10) {
-        s = '0' + s;
-    }
-
-    return String(10000*Y + 100*m + d + '.' + H + i + s);
-}
-
-function setVersion(type) {
-  fs.writeFile(`./assets/bust/${type}.txt`, version(), function(err) {
-    if(err) {
-        return console.log(err);
-    }
-  }); 
-}
 
 function buildMainCSS(){
 
@@ -59,7 +26,6 @@ function buildMainCSS(){
   . pipe(concat('main.min.css'))
   . pipe(gulp.dest('static/dist/css'));
 
-  setVersion('css');
   return merge(min, full);
 }
 
@@ -82,7 +48,6 @@ function buildHomeCSS(){
   . pipe(concat('home-page.min.css'))
   . pipe(gulp.dest('static/dist/css'));
 
-  setVersion('css');
   return merge(full, min);
 }
 
@@ -104,7 +69,6 @@ function buildAdminCSS(){
   . pipe(concat('admin.min.css'))
   . pipe(gulp.dest('static/dist/css'));
 
-  setVersion('css');
   return merge(full, min);
 }
 
@@ -126,28 +90,24 @@ function buildHomeJS() {
   .pipe(uglify())
   .pipe(gulp.dest('static/dist/js'));
 
-  setVersion('css');
   return merge(full, min);
 }
 
-function buildArticleJS() {
+function buildMainJS() {
 
   var full = gulp.src([
     'assets/src/js/main.js',
-    'assets/src/js/article.js',
   ])
-  .pipe(concat('article.js'))
+  .pipe(concat('main.js'))
   .pipe(gulp.dest('static/dist/js'));
 
   var min = gulp.src([
     'assets/src/js/main.js',
-    'assets/src/js/article.js',
   ])
-  .pipe(concat('article.min.js'))
+  .pipe(concat('main.min.js'))
   .pipe(uglify())
   .pipe(gulp.dest('static/dist/js'));
 
-  setVersion('js');
   return merge(min, full);
 
 }
@@ -163,27 +123,24 @@ function buildHashJS(){
   .pipe(uglify())
   .pipe(gulp.dest('static/dist/js/tools'));
 
-  setVersion('js');
   return merge(hash);
 }
 
-function buildAdminJS() {
+function buildPasskeysJS() {
 
   var full = gulp.src([
-    'assets/src/js/admin.js'
+    'assets/src/js/passkeys.js'
   ])
-  .pipe(concat('admin.js'))
-  .pipe(uglify())
+  .pipe(concat('passkeys.js'))
   .pipe(gulp.dest('static/dist/js'));
 
   var min = gulp.src([
-    'assets/src/js/admin.js'
+    'assets/src/js/passkeys.js'
   ])
-  .pipe(concat('admin.min.js'))
+  .pipe(concat('passkeys.min.js'))
   .pipe(uglify())
   .pipe(gulp.dest('static/dist/js'));
 
-  setVersion('js');
   return merge(full, min);
 }
 
@@ -193,8 +150,8 @@ function watchFiles() {
   gulp.watch(['./assets/src/scss/main.scss', './assets/src/scss/navbar.scss', './assets/src/scss/home-page.scss'], buildHomeCSS);
   gulp.watch(['./assets/src/scss/admin.scss', './assets/src/scss/navbar.scss'], buildAdminCSS);
   gulp.watch(['./assets/src/js/main.js', './assets/src/js/home.js'], buildHomeJS);
-  gulp.watch(['./assets/src/js/main.js', './assets/src/js/article.js'], buildArticleJS);
-  gulp.watch('./assets/src/js/admin.js', buildAdminJS);
+  gulp.watch('./assets/src/js/main.js', buildMainJS);
+  gulp.watch('./assets/src/js/passkeys.js', buildPasskeysJS);
 }
 
 gulp.task('build-admin-css', buildAdminCSS);
@@ -205,9 +162,9 @@ gulp.task('build-home-css', buildHomeCSS);
 
 gulp.task('build-home-js', buildHomeJS);
 
-gulp.task('build-article-js', buildArticleJS);
+gulp.task('build-main-js', buildMainJS);
 
-gulp.task('build-admin-js', buildAdminJS);
+gulp.task('build-passkeys-js', buildPasskeysJS);
 
 gulp.task('build-hash-js', buildHashJS);
 
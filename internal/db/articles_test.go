@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func createTestArticle(t *testing.T, db *DB, title, articleType string) string {
+	t.Helper()
+
+	id, err := db.CreateArticle(types.Article{Title: title})
+	if err != nil {
+		t.Fatalf("CreateArticle(%q) returned an error: %v", title, err)
+	}
+
+	if articleType != "" {
+		_, err = db.DB.Exec(context.Background(), "UPDATE articles SET type=$1 WHERE id=$2", articleType, id)
+		if err != nil {
+			t.Fatalf("set article type failed: %v", err)
+		}
+	}
+
+	return id
+}
+
+func TestCreateArticle(t *testing.T) {
+	db := newTestDB(t)
+	createTestArticle(t, db, "Taken Title", "")
+
+	tests := []struct {
+		name     string
+		title    string
+		wantSlug string
+		wantErr  bool
+	}{
+		{"simple", "Hello", "Hello", false},
+		{"spaces", "Hello World", "Hello-World", false},
+		{"punctuation", "Go: the good parts!", "Go-the-good-parts", false},
+		{"duplicate slug", "Taken Title", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := db.CreateArticle(types.Article{Title: test.title})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CreateArticle(%q) error = %v; wantErr %v", test.title, err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			article, err := db.FetchArticleByID(id)
+			if err != nil {
+				t.Fatalf("FetchArticleByID(%q) returned an error: %v", id, err)
+			}
+			if article.Title != test.title {
+				t.Errorf("Title = %q; want %q", article.Title, test.title)
+			}
+			if article.Slug != test.wantSlug {
+				t.Errorf("Slug = %q; want %q", article.Slug, test.wantSlug)
+			}
+			if article.Body.Valid || article.Published.Valid {
+				t.Errorf("new article has body or published set: %+v", article)
+			}
+		})
+	}
+}
+
+func TestFetchArticles(t *testing.T) {
+	db := newTestDB(t)
+
+	articles, err := db.FetchArticles()
+	if err != nil {
+		t.Fatalf("FetchArticles returned an error: %v", err)
+	}
+	if len(articles) != 0 {
+		t.Fatalf("FetchArticles returned %d articles on an empty table; want 0", len(articles))
+	}
+
+	createTestArticle(t, db, "One", "post")
+	createTestArticle(t, db, "Two", "")
+
+	articles, err = db.FetchArticles()
+	if err != nil {
+		t.Fatalf("FetchArticles returned an error: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("FetchArticles returned %d articles; want 2", len(articles))
+	}
+}
+
+func TestFetchArticlesByType(t *testing.T) {
+	db := newTestDB(t)
+	createTestArticle(t, db, "Post One", "post")
+	createTestArticle(t, db, "Post Two", "post")
+	createTestArticle(t, db, "Game", "game")
+	createTestArticle(t, db, "Untyped", "")
+
+	tests := []struct {
+		articleType string
+		want        int
+	}{
+		{"post", 2},
+		{"game", 1},
+		{"tool", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.articleType, func(t *testing.T) {
+			articles, err := db.FetchArticlesByType(test.articleType)
+			if err != nil {
+				t.Fatalf("FetchArticlesByType(%q) returned an error: %v", test.articleType, err)
+			}
+			if len(articles) != test.want {
+				t.Errorf("FetchArticlesByType(%q) returned %d articles; want %d", test.articleType, len(articles), test.want)
+			}
+		})
+	}
+}
+
+func TestFetchArticleByID(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestArticle(t, db, "Hello", "post")
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{"existing", id, false},
+		{"missing", "00000000-0000-0000-0000-000000000000", true},
+		{"malformed", "not-a-uuid", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			article, err := db.FetchArticleByID(test.id)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FetchArticleByID(%q) error = %v; wantErr %v", test.id, err, test.wantErr)
+			}
+			if !test.wantErr && article.Type.String != "post" {
+				t.Errorf("FetchArticleByID(%q).Type = %q; want %q", test.id, article.Type.String, "post")
+			}
+			if !test.wantErr && article.Updated.IsZero() {
+				t.Errorf("FetchArticleByID(%q).Updated is zero", test.id)
+			}
+		})
+	}
+}
+
+func TestFetchArticleBySlug(t *testing.T) {
+	db := newTestDB(t)
+	createTestArticle(t, db, "Hello World", "post")
+
+	tests := []struct {
+		slug    string
+		wantErr bool
+	}{
+		{"Hello-World", false},
+		{"hello-world", true},
+		{"missing", true},
+		{"", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.slug, func(t *testing.T) {
+			article, err := db.FetchArticleBySlug(test.slug)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FetchArticleBySlug(%q) error = %v; wantErr %v", test.slug, err, test.wantErr)
+			}
+			if !test.wantErr && article.Title != "Hello World" {
+				t.Errorf("FetchArticleBySlug(%q).Title = %q; want %q", test.slug, article.Title, "Hello World")
+			}
+		})
+	}
+}
+
+func TestFetchMetaDataBySlug(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestArticle(t, db, "games", "")
+
+	_, err := db.DB.Exec(context.Background(), "UPDATE articles SET description=$1, keywords=$2 WHERE id=$3", "All the games", "games, fun", id)
+	if err != nil {
+		t.Fatalf("set article metadata failed: %v", err)
+	}
+
+	meta, err := db.FetchMetaDataBySlug("games")
+	if err != nil {
+		t.Fatalf("FetchMetaDataBySlug returned an error: %v", err)
+	}
+	if meta.Description.String != "All the games" || meta.Keywords.String != "games, fun" {
+		t.Errorf("FetchMetaDataBySlug = %+v; want description and keywords set", meta)
+	}
+	if meta.Body.Valid {
+		t.Errorf("FetchMetaDataBySlug loaded the body")
+	}
+
+	if _, err := db.FetchMetaDataBySlug("tools"); err == nil {
+		t.Errorf("FetchMetaDataBySlug returned no error for a missing slug")
+	}
+}
+
+func TestDeleteArticle(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestArticle(t, db, "Hello", "")
+
+	if err := db.DeleteArticle(id); err != nil {
+		t.Fatalf("DeleteArticle returned an error: %v", err)
+	}
+
+	if _, err := db.FetchArticleByID(id); err == nil {
+		t.Fatalf("FetchArticleByID found a deleted article")
+	}
+
+	if err := db.DeleteArticle("not-a-uuid"); err == nil {
+		t.Fatalf("DeleteArticle returned no error for a malformed id")
+	}
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func (db *DB) CreateAuditEvent(event types.AuditEvent) (string, error) {
+
+	eventID := uuid.New().String()
+
+	sql := `INSERT INTO audit_events (id, actor, action, target_type, target_id, before, after, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := db.DB.Exec(context.Background(), sql, eventID, event.Actor, event.Action, event.TargetType, event.TargetID, nullJSON(event.Before), nullJSON(event.After), event.IP)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	return eventID, nil
+}
+
+func (db *DB) FetchAuditEventsByTarget(targetType, targetID string) ([]types.AuditEvent, error) {
+	return db.FetchAuditEvents(types.AuditFilter{TargetType: targetType, TargetID: targetID})
+}
+
+// FetchAuditEvents returns the events matching filter, newest first.
+func (db *DB) FetchAuditEvents(filter types.AuditFilter) ([]types.AuditEvent, error) {
+	var where []string
+	var args []interface{}
+
+	add := func(clause string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.Actor != "" {
+		add("lower(actor) = lower($%d)", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To)
+	}
+
+	sql := `SELECT id, created_at, actor, action, target_type, target_id, before, after, ip FROM audit_events`
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " ORDER BY created_at DESC, id"
+
+	if filter.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	if filter.Offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", filter.Offset)
+	}
+
+	rows, err := db.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var events []types.AuditEvent
+	for rows.Next() {
+		var event types.AuditEvent
+		var before, after []byte
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.Actor, &event.Action, &event.TargetType, &event.TargetID, &before, &after, &event.IP)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		event.Before = before
+		event.After = after
+		events = append(events, event)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows iteration failed: %v", rows.Err())
+	}
+
+	return events, nil
+}
+
+// FetchAuditActions lists every action recorded so far, for filtering by.
+func (db *DB) FetchAuditActions() ([]string, error) {
+	rows, err := db.DB.Query(context.Background(), `SELECT DISTINCT action FROM audit_events ORDER BY action`)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		actions = append(actions, action)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows iteration failed: %v", rows.Err())
+	}
+
+	return actions, nil
+}
+
+// nullJSON stores an empty snapshot as NULL rather than invalid JSON.
+func nullJSON(raw []byte) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func TestAuditEvents(t *testing.T) {
+	db := newTestDB(t)
+
+	events := []types.AuditEvent{
+		{Action: "user.locked", TargetType: "user", TargetID: "one", After: json.RawMessage(`{"failures": 5}`), IP: "192.0.2.1"},
+		{Actor: "admin@example.com", Action: "user.unlocked", TargetType: "user", TargetID: "one", IP: "192.0.2.2"},
+		{Action: "user.locked", TargetType: "user", TargetID: "two"},
+	}
+
+	for _, event := range events {
+		if _, err := db.CreateAuditEvent(event); err != nil {
+			t.Fatalf("CreateAuditEvent(%+v) returned an error: %v", event, err)
+		}
+	}
+
+	if _, err := db.CreateAuditEvent(types.AuditEvent{Action: "bad", After: json.RawMessage(`{`)}); err == nil {
+		t.Errorf("CreateAuditEvent returned no error for invalid JSON")
+	}
+
+	got, err := db.FetchAuditEventsByTarget("user", "one")
+	if err != nil {
+		t.Fatalf("FetchAuditEventsByTarget returned an error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("FetchAuditEventsByTarget returned %d events; want 2", len(got))
+	}
+
+	for _, event := range got {
+		switch event.Action {
+		case "user.locked":
+			if event.Actor != "" || event.IP != "192.0.2.1" || len(event.Before) != 0 {
+				t.Errorf("locked event = %+v", event)
+			}
+			var after map[string]int
+			if err := json.Unmarshal(event.After, &after); err != nil || after["failures"] != 5 {
+				t.Errorf("locked event After = %s; want failures 5", event.After)
+			}
+		case "user.unlocked":
+			if event.Actor != "admin@example.com" || len(event.After) != 0 {
+				t.Errorf("unlocked event = %+v", event)
+			}
+		default:
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestFetchAuditEvents(t *testing.T) {
+	db := newTestDB(t)
+
+	events := []types.AuditEvent{
+		{Actor: "Admin@example.com", Action: "user.created", TargetType: "user", TargetID: "one"},
+		{Actor: "admin@example.com", Action: "article.updated", TargetType: "article", TargetID: "two"},
+		{Actor: "grace@example.com", Action: "user.updated", TargetType: "user", TargetID: "one"},
+		{Action: "user.locked", TargetType: "user", TargetID: "three"},
+	}
+	for _, event := range events {
+		if _, err := db.CreateAuditEvent(event); err != nil {
+			t.Fatalf("CreateAuditEvent(%+v) returned an error: %v", event, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter types.AuditFilter
+		want   int
+	}{
+		{"everything", types.AuditFilter{}, 4},
+		{"actor ignores case", types.AuditFilter{Actor: "ADMIN@example.com"}, 2},
+		{"action", types.AuditFilter{Action: "user.updated"}, 1},
+		{"target", types.AuditFilter{TargetType: "user", TargetID: "one"}, 2},
+		{"actor and target type", types.AuditFilter{Actor: "admin@example.com", TargetType: "user"}, 1},
+		{"since now", types.AuditFilter{From: time.Now().Add(time.Minute)}, 0},
+		{"until now", types.AuditFilter{To: time.Now().Add(time.Minute)}, 4},
+		{"limit", types.AuditFilter{Limit: 3}, 3},
+		{"offset", types.AuditFilter{Limit: 3, Offset: 3}, 1},
+		{"no match", types.AuditFilter{Action: "article.deleted"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := db.FetchAuditEvents(test.filter)
+			if err != nil {
+				t.Fatalf("FetchAuditEvents returned an error: %v", err)
+			}
+			if len(got) != test.want {
+				t.Errorf("FetchAuditEvents(%+v) returned %d events; want %d", test.filter, len(got), test.want)
+			}
+		})
+	}
+
+	actions, err := db.FetchAuditActions()
+	if err != nil {
+		t.Fatalf("FetchAuditActions returned an error: %v", err)
+	}
+	want := []string{"article.updated", "user.created", "user.locked", "user.updated"}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Errorf("FetchAuditActions = %v; want %v", actions, want)
+	}
+}
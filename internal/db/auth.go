@@ -2,20 +2,51 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
 )
 
+// ErrNoCredentials is returned by FetchAuth when no user has the email or
+// the user has no password set.
+var ErrNoCredentials = errors.New("no user with that email and a password")
+
 func (db *DB) FetchAuth(email string) (types.AuthUser, error) {
 
 	var user types.AuthUser
-	sql := "SELECT id, first_name, last_name, email, hash FROM users WHERE email=$1"
+	var hash *string
+	sql := `SELECT id, first_name, last_name, email, hash, role, email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL,
+		EXISTS (SELECT 1 FROM passkeys WHERE user_id = users.id)
+		FROM users WHERE email=$1`
 
-	err := db.DB.QueryRow(context.Background(), sql, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Hash)
+	err := db.DB.QueryRow(context.Background(), sql, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &hash, &user.Role, &user.EmailVerified, &user.TwoFactor, &user.Passkey)
+	if err == pgx.ErrNoRows {
+		return types.AuthUser{}, ErrNoCredentials
+	}
 	if err != nil {
 		return user, fmt.Errorf("query failed: %v", err)
 	}
 
+	if hash == nil || *hash == "" {
+		return user, ErrNoCredentials
+	}
+	user.Hash = *hash
+
 	return user, nil
 }
+
+// RehashPassword replaces a user's password hash with one made by the
+// current hasher. It does nothing if the hash changed since old was read,
+// so a login cannot undo a password reset that raced it.
+func (db *DB) RehashPassword(userID, old, hash string) error {
+	sql := `UPDATE users SET hash=$1 WHERE id=$2 AND hash=$3`
+
+	_, err := db.DB.Exec(context.Background(), sql, hash, userID, old)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	return nil
+}
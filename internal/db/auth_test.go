@@ -0,0 +1,95 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+)
+
+func TestFetchAuth(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := db.RegisterUser(types.RegisterUser{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		Password:  "correct horse battery",
+	})
+	if err != nil {
+		t.Fatalf("RegisterUser returned an error: %v", err)
+	}
+
+	// Users created from the admin area have no password hash yet.
+	createTestUser(t, db, "grace@example.com")
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{"registered", "ada@example.com", nil},
+		{"no password", "grace@example.com", ErrNoCredentials},
+		{"unknown", "nobody@example.com", ErrNoCredentials},
+		{"empty", "", ErrNoCredentials},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, err := db.FetchAuth(test.email)
+			if err != test.wantErr {
+				t.Fatalf("FetchAuth(%q) error = %v; want %v", test.email, err, test.wantErr)
+			}
+			if test.wantErr != nil {
+				return
+			}
+
+			if !passwords.CheckPasswordHash("correct horse battery", user.Hash) {
+				t.Errorf("FetchAuth(%q) returned a hash that does not match the password", test.email)
+			}
+		})
+	}
+}
+
+func TestRehashPassword(t *testing.T) {
+	db := newTestDB(t)
+
+	_, err := db.RegisterUser(types.RegisterUser{
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Email:     "ada@example.com",
+		Password:  "correct horse battery",
+	})
+	if err != nil {
+		t.Fatalf("RegisterUser returned an error: %v", err)
+	}
+
+	user, err := db.FetchAuth("ada@example.com")
+	if err != nil {
+		t.Fatalf("FetchAuth returned an error: %v", err)
+	}
+
+	hash, err := passwords.Bcrypt{Cost: 4}.Hash("correct horse battery")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A hash read before it changed is left alone
+	err = db.RehashPassword(user.ID, "stale", hash)
+	if err != nil {
+		t.Fatalf("RehashPassword returned an error: %v", err)
+	}
+	after, _ := db.FetchAuth("ada@example.com")
+	if after.Hash != user.Hash {
+		t.Fatalf("RehashPassword replaced a hash that had changed")
+	}
+
+	err = db.RehashPassword(user.ID, user.Hash, hash)
+	if err != nil {
+		t.Fatalf("RehashPassword returned an error: %v", err)
+	}
+	after, _ = db.FetchAuth("ada@example.com")
+	if after.Hash != hash {
+		t.Errorf("hash = %q; want %q", after.Hash, hash)
+	}
+}
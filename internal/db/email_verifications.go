@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+// ErrVerificationInvalid is returned for a verification link that does not
+// exist, has expired, has been used or was sent to an address the user no
+// longer has.
+var ErrVerificationInvalid = errors.New("verification link is invalid or has expired")
+
+// CreateEmailVerification stores a link verifying email for a user under the
+// hash of its token, replacing any earlier link the user has not used.
+func (db *DB) CreateEmailVerification(userID, email, tokenHash string, expiresAt time.Time) (string, error) {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM email_verifications WHERE user_id=$1 AND used_at IS NULL", userID)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	verificationID := uuid.New().String()
+	sql := "INSERT INTO email_verifications (id, user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err = tx.Exec(ctx, sql, verificationID, userID, email, tokenHash, expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return verificationID, nil
+}
+
+// RecentEmailVerification reports whether a verification link was sent to
+// the user after since.
+func (db *DB) RecentEmailVerification(userID string, since time.Time) (bool, error) {
+	var recent bool
+	sql := "SELECT EXISTS (SELECT 1 FROM email_verifications WHERE user_id=$1 AND created_at > $2)"
+	err := db.DB.QueryRow(context.Background(), sql, userID, since).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("query failed: %v", err)
+	}
+
+	return recent, nil
+}
+
+// VerifyEmail uses a verification link and returns the user whose address
+// it verified.
+func (db *DB) VerifyEmail(tokenHash string) (types.User, error) {
+	ctx := context.Background()
+	var user types.User
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return user, fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID, email string
+	sql := `UPDATE email_verifications SET used_at = now()
+		WHERE token_hash=$1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id, email`
+	err = tx.QueryRow(ctx, sql, tokenHash).Scan(&userID, &email)
+	if err == pgx.ErrNoRows {
+		return user, ErrVerificationInvalid
+	}
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	sql = `UPDATE users SET email_verified_at = now() WHERE id=$1 AND email=$2
+		RETURNING id, first_name, last_name, email, role, email_verified_at`
+	err = tx.QueryRow(ctx, sql, userID, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
+	if err == pgx.ErrNoRows {
+		return user, ErrVerificationInvalid
+	}
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return user, fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	user, _ := db.FetchUserById(id)
+	if user.EmailVerifiedAt.Valid {
+		t.Fatalf("a new user is already verified")
+	}
+
+	if _, err := db.CreateEmailVerification(id, "ada@example.com", "current", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateEmailVerification returned an error: %v", err)
+	}
+
+	user, err := db.VerifyEmail("current")
+	if err != nil {
+		t.Fatalf("VerifyEmail returned an error: %v", err)
+	}
+	if user.ID != id || !user.EmailVerifiedAt.Valid {
+		t.Errorf("VerifyEmail = %+v; want user %q verified", user, id)
+	}
+
+	if _, err := db.VerifyEmail("current"); err != ErrVerificationInvalid {
+		t.Errorf("VerifyEmail reused a link: error = %v", err)
+	}
+
+	// A link sent to an address the user has since changed
+	if _, err := db.CreateEmailVerification(id, "old@example.com", "stale", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreateEmailVerification returned an error: %v", err)
+	}
+	if _, err := db.VerifyEmail("stale"); err != ErrVerificationInvalid {
+		t.Errorf("VerifyEmail verified an old address: error = %v", err)
+	}
+
+	if _, err := db.CreateEmailVerification(id, "ada@example.com", "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreateEmailVerification returned an error: %v", err)
+	}
+	if _, err := db.VerifyEmail("expired"); err != ErrVerificationInvalid {
+		t.Errorf("VerifyEmail used an expired link: error = %v", err)
+	}
+}
+
+func TestFetchAuthEmailVerified(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	_, err := db.DB.Exec(context.Background(), "UPDATE users SET hash='x' WHERE id=$1", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := db.FetchAuth("ada@example.com")
+	if err != nil || auth.EmailVerified {
+		t.Fatalf("FetchAuth = %+v, %v; want an unverified user", auth, err)
+	}
+
+	db.CreateEmailVerification(id, "ada@example.com", "token", time.Now().Add(time.Hour))
+	db.VerifyEmail("token")
+
+	auth, err = db.FetchAuth("ada@example.com")
+	if err != nil || !auth.EmailVerified {
+		t.Errorf("FetchAuth = %+v, %v; want a verified user", auth, err)
+	}
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+// FetchUserByIdentity returns the user an identity provider account is
+// linked to, or ErrUserNotFound if it is not linked to one.
+func (db *DB) FetchUserByIdentity(issuer, subject string) (types.User, error) {
+	var user types.User
+	sql := `SELECT u.id, u.first_name, u.last_name, u.email, u.role, u.email_verified_at
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.issuer=$1 AND i.subject=$2`
+	err := db.DB.QueryRow(context.Background(), sql, issuer, subject).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
+	if err == pgx.ErrNoRows {
+		return user, ErrUserNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	return user, nil
+}
+
+// LinkIdentity links an identity provider account to a user, or records
+// another login if it already is. The provider has verified email, so if
+// it is still the user's address it counts as verified here too.
+func (db *DB) LinkIdentity(userID, issuer, subject, email string) error {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `INSERT INTO user_identities (issuer, subject, user_id, email) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO UPDATE SET email=EXCLUDED.email, last_login_at=now()
+		WHERE user_identities.user_id=EXCLUDED.user_id`
+	tag, err := tx.Exec(ctx, sql, issuer, subject, userID, email)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("identity %s is linked to another user", subject)
+	}
+
+	sql = "UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id=$1 AND email=$2"
+	_, err = tx.Exec(ctx, sql, userID, email)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return nil
+}
+
+// ProvisionUser creates a user for someone signing in through an identity
+// provider for the first time, with their email already verified, and
+// links the identity to them.
+func (db *DB) ProvisionUser(user types.User, issuer, subject string) (string, error) {
+	ctx := context.Background()
+	userID := uuid.New().String()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "INSERT INTO users (id, first_name, last_name, email, role, email_verified_at) VALUES ($1, $2, $3, $4, $5, now())"
+	_, err = tx.Exec(ctx, sql, userID, user.FirstName, user.LastName, user.Email, user.Role)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	sql = "INSERT INTO user_identities (issuer, subject, user_id, email) VALUES ($1, $2, $3, $4)"
+	_, err = tx.Exec(ctx, sql, issuer, subject, userID, user.Email)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func TestIdentities(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+	other := createTestUser(t, db, "grace@example.com")
+
+	if _, err := db.FetchUserByIdentity("https://idp.example.com", "ada"); err != ErrUserNotFound {
+		t.Errorf("FetchUserByIdentity before linking = %v; want ErrUserNotFound", err)
+	}
+
+	if err := db.LinkIdentity(id, "https://idp.example.com", "ada", "ada@example.com"); err != nil {
+		t.Fatalf("LinkIdentity returned an error: %v", err)
+	}
+	if err := db.LinkIdentity(id, "https://idp.example.com", "ada", "ada@example.com"); err != nil {
+		t.Errorf("LinkIdentity a second time returned an error: %v", err)
+	}
+	if err := db.LinkIdentity(other, "https://idp.example.com", "ada", "grace@example.com"); err == nil {
+		t.Error("LinkIdentity moved an identity to another user")
+	}
+
+	user, err := db.FetchUserByIdentity("https://idp.example.com", "ada")
+	if err != nil {
+		t.Fatalf("FetchUserByIdentity returned an error: %v", err)
+	}
+	if user.ID != id || !user.EmailVerifiedAt.Valid {
+		t.Errorf("FetchUserByIdentity = %+v; want user %s with a verified email", user, id)
+	}
+
+	if _, err := db.FetchUserByIdentity("https://other.example.com", "ada"); err != ErrUserNotFound {
+		t.Errorf("FetchUserByIdentity from another issuer = %v; want ErrUserNotFound", err)
+	}
+
+	newID, err := db.ProvisionUser(types.User{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Role: "user"}, "https://idp.example.com", "jane")
+	if err != nil {
+		t.Fatalf("ProvisionUser returned an error: %v", err)
+	}
+
+	user, err = db.FetchUserByIdentity("https://idp.example.com", "jane")
+	if err != nil || user.ID != newID || user.Role != "user" || !user.EmailVerifiedAt.Valid {
+		t.Errorf("FetchUserByIdentity after ProvisionUser = %+v, %v", user, err)
+	}
+
+	if _, err := db.ProvisionUser(types.User{FirstName: "Ada", LastName: "Again", Email: "ada@example.com", Role: "user"}, "https://idp.example.com", "ada2"); err == nil {
+		t.Error("ProvisionUser created a second user with a taken email")
+	}
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+)
+
+// ErrInvitationInvalid is returned for an invitation that does not exist,
+// has expired or has already been accepted.
+var ErrInvitationInvalid = errors.New("invitation is invalid or has expired")
+
+const invitationColumns = "id, email, role, invited_by, created_at, expires_at, accepted_at"
+
+func scanInvitation(row pgx.Row) (types.Invitation, error) {
+	var invitation types.Invitation
+	err := row.Scan(&invitation.ID, &invitation.Email, &invitation.Role, &invitation.InvitedBy, &invitation.CreatedAt, &invitation.ExpiresAt, &invitation.AcceptedAt)
+	return invitation, err
+}
+
+// CreateInvitation stores an invitation under the hash of its token.
+func (db *DB) CreateInvitation(invitation types.Invitation, tokenHash string) (string, error) {
+
+	invitationID := uuid.New().String()
+
+	sql := `INSERT INTO invitations (id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := db.DB.Exec(context.Background(), sql, invitationID, invitation.Email, invitation.Role, tokenHash, invitation.InvitedBy, invitation.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	return invitationID, nil
+}
+
+func (db *DB) FetchInvitations() ([]types.Invitation, error) {
+	sql := "SELECT " + invitationColumns + " FROM invitations ORDER BY created_at DESC"
+	rows, err := db.DB.Query(context.Background(), sql)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var invitations []types.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows iteration failed: %v", rows.Err())
+	}
+
+	return invitations, nil
+}
+
+func (db *DB) FetchInvitationById(id string) (types.Invitation, error) {
+	sql := "SELECT " + invitationColumns + " FROM invitations WHERE id=$1"
+	invitation, err := scanInvitation(db.DB.QueryRow(context.Background(), sql, id))
+	if err != nil {
+		return invitation, fmt.Errorf("query failed: %v", err)
+	}
+
+	return invitation, nil
+}
+
+// FetchPendingInvitation returns the invitation a token was issued for if it
+// can still be accepted.
+func (db *DB) FetchPendingInvitation(tokenHash string) (types.Invitation, error) {
+	sql := "SELECT " + invitationColumns + " FROM invitations WHERE token_hash=$1 AND accepted_at IS NULL AND expires_at > now()"
+	invitation, err := scanInvitation(db.DB.QueryRow(context.Background(), sql, tokenHash))
+	if err == pgx.ErrNoRows {
+		return invitation, ErrInvitationInvalid
+	}
+	if err != nil {
+		return invitation, fmt.Errorf("query failed: %v", err)
+	}
+
+	return invitation, nil
+}
+
+// AcceptInvitation marks the invitation used and creates its user in one
+// transaction, so a token registers at most one account. The user gets the
+// invitation's email and role whatever was submitted, and as the invitation
+// reached that address it counts as verified.
+func (db *DB) AcceptInvitation(tokenHash string, user types.RegisterUser) (string, error) {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var email, role string
+	sql := `UPDATE invitations SET accepted_at = now()
+		WHERE token_hash=$1 AND accepted_at IS NULL AND expires_at > now()
+		RETURNING email, role`
+	err = tx.QueryRow(ctx, sql, tokenHash).Scan(&email, &role)
+	if err == pgx.ErrNoRows {
+		return "", ErrInvitationInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	hash, err := passwords.HashPassword(user.Password)
+	if err != nil {
+		return "", fmt.Errorf("hash password failed: %v", err)
+	}
+
+	userID := uuid.New().String()
+	sql = "INSERT INTO users (id, first_name, last_name, email, hash, role, email_verified_at) VALUES ($1, $2, $3, $4, $5, $6, now())"
+	_, err = tx.Exec(ctx, sql, userID, user.FirstName, user.LastName, email, hash, role)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return userID, nil
+}
+
+func (db *DB) DeleteInvitation(id string) error {
+	sql := "DELETE FROM invitations WHERE id=$1"
+	_, err := db.DB.Exec(context.Background(), sql, id)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	return nil
+}
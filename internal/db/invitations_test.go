@@ -0,0 +1,96 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func TestAcceptInvitation(t *testing.T) {
+	db := newTestDB(t)
+
+	invitation := types.Invitation{Email: "grace@example.com", Role: "admin", InvitedBy: "ada@example.com", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := db.CreateInvitation(invitation, "pending"); err != nil {
+		t.Fatalf("CreateInvitation returned an error: %v", err)
+	}
+
+	invitation.ExpiresAt = time.Now().Add(-time.Hour)
+	if _, err := db.CreateInvitation(invitation, "expired"); err != nil {
+		t.Fatalf("CreateInvitation returned an error: %v", err)
+	}
+
+	if _, err := db.FetchPendingInvitation("expired"); err != ErrInvitationInvalid {
+		t.Errorf("FetchPendingInvitation(expired) error = %v; want %v", err, ErrInvitationInvalid)
+	}
+
+	pending, err := db.FetchPendingInvitation("pending")
+	if err != nil {
+		t.Fatalf("FetchPendingInvitation(pending) returned an error: %v", err)
+	}
+	if pending.Email != "grace@example.com" || pending.Role != "admin" {
+		t.Errorf("FetchPendingInvitation(pending) = %+v", pending)
+	}
+
+	user := types.RegisterUser{FirstName: "Grace", LastName: "Hopper", Email: "someone@else.com", Password: "correct horse battery"}
+
+	id, err := db.AcceptInvitation("pending", user)
+	if err != nil {
+		t.Fatalf("AcceptInvitation returned an error: %v", err)
+	}
+
+	got, err := db.FetchUserById(id)
+	if err != nil {
+		t.Fatalf("FetchUserById returned an error: %v", err)
+	}
+	if got.Email != "grace@example.com" || got.Role != "admin" {
+		t.Errorf("accepted user = %+v; want the invitation's email and role", got)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"used twice", "pending"},
+		{"expired", "expired"},
+		{"unknown", "unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := db.AcceptInvitation(test.hash, user); err != ErrInvitationInvalid {
+				t.Errorf("AcceptInvitation(%q) error = %v; want %v", test.hash, err, ErrInvitationInvalid)
+			}
+		})
+	}
+
+	users, _ := db.FetchUsers()
+	if len(users) != 1 {
+		t.Errorf("FetchUsers returned %d users; want 1", len(users))
+	}
+}
+
+func TestDeleteInvitation(t *testing.T) {
+	db := newTestDB(t)
+
+	id, err := db.CreateInvitation(types.Invitation{Email: "grace@example.com", Role: "user", ExpiresAt: time.Now().Add(time.Hour)}, "token")
+	if err != nil {
+		t.Fatalf("CreateInvitation returned an error: %v", err)
+	}
+
+	if err := db.DeleteInvitation(id); err != nil {
+		t.Fatalf("DeleteInvitation returned an error: %v", err)
+	}
+
+	invitations, err := db.FetchInvitations()
+	if err != nil {
+		t.Fatalf("FetchInvitations returned an error: %v", err)
+	}
+	if len(invitations) != 0 {
+		t.Errorf("FetchInvitations returned %d invitations after delete; want 0", len(invitations))
+	}
+
+	if _, err := db.FetchPendingInvitation("token"); err != ErrInvitationInvalid {
+		t.Errorf("FetchPendingInvitation after delete error = %v; want %v", err, ErrInvitationInvalid)
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool is shared by every test in the package. It is nil when no
+// Postgres could be started, in which case the integration tests skip.
+var testPool *pgxpool.Pool
+
+// TestMain boots a disposable Postgres for the package. Set TEST_DATABASE_URL
+// to reuse a throwaway server instead; otherwise initdb and pg_ctl are looked
+// up in PG_BIN, on the PATH and under /usr/lib/postgresql.
+func TestMain(m *testing.M) {
+	url := os.Getenv("TEST_DATABASE_URL")
+
+	var stop func()
+	if url == "" {
+		var err error
+		url, stop, err = startPostgres()
+		if err != nil {
+			log.Printf("Skipping database tests: %v", err)
+		}
+	}
+
+	if url != "" {
+		pool, err := pgxpool.New(context.Background(), url)
+		if err != nil {
+			log.Fatalf("Unable to create connection pool: %v", err)
+		}
+
+		db := DB{DB: pool}
+		err = db.Migrate()
+		if err != nil {
+			log.Fatalf("Migrate failed: %v", err)
+		}
+
+		testPool = pool
+	}
+
+	code := m.Run()
+
+	if testPool != nil {
+		testPool.Close()
+	}
+	if stop != nil {
+		stop()
+	}
+
+	os.Exit(code)
+}
+
+// newTestDB returns a DB backed by the disposable server with every table
+// emptied, or skips the test when no server is available.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	if testPool == nil {
+		t.Skip("no Postgres available; install it locally or set TEST_DATABASE_URL")
+	}
+
+	_, err := testPool.Exec(context.Background(), "TRUNCATE users, articles, audit_events, invitations, password_resets, email_verifications, recovery_codes, passkeys, user_identities")
+	if err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+
+	return &DB{DB: testPool}
+}
+
+// pgBinary finds a Postgres server binary by name.
+func pgBinary(name string) (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		return filepath.Join(dir, name), nil
+	}
+
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+
+	matches, _ := filepath.Glob(filepath.Join("/usr/lib/postgresql/*/bin", name))
+	if len(matches) > 0 {
+		return matches[len(matches)-1], nil
+	}
+
+	return "", fmt.Errorf("%s not found", name)
+}
+
+// startPostgres initialises a cluster in a temporary directory and starts it
+// on a free localhost port. The returned func stops the server and removes
+// the directory.
+func startPostgres() (string, func(), error) {
+	initdb, err := pgBinary("initdb")
+	if err != nil {
+		return "", nil, err
+	}
+
+	pgctl, err := pgBinary("pg_ctl")
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "jasonsnider-pg-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := filepath.Join(dir, "data")
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("initdb failed: %v: %s", err, out)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -F", port, dir)
+	out, err = exec.Command(pgctl, "-D", data, "-l", filepath.Join(dir, "postgres.log"), "-o", options, "-w", "start").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("pg_ctl start failed: %v: %s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgctl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+		os.RemoveAll(dir)
+	}
+
+	url := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	return url, stop, nil
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
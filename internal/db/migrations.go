@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies every migration under migrations/ that has not yet been
+// recorded in the schema_migrations table. Files are applied in name order,
+// each in its own transaction.
+func (db *DB) Migrate() error {
+	ctx := context.Background()
+
+	sql := "CREATE TABLE IF NOT EXISTS schema_migrations (version TEXT PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())"
+	_, err := db.DB.Exec(ctx, sql)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations failed: %v", err)
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("glob migrations failed: %v", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		version := strings.TrimSuffix(strings.TrimPrefix(file, "migrations/"), ".sql")
+
+		var applied bool
+		err := db.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version=$1)", version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("query failed: %v", err)
+		}
+		if applied {
+			continue
+		}
+
+		contents, err := migrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read %s failed: %v", file, err)
+		}
+
+		tx, err := db.DB.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin transaction failed: %v", err)
+		}
+
+		_, err = tx.Exec(ctx, string(contents))
+		if err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migration %s failed: %v", version, err)
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+		if err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("record migration %s failed: %v", version, err)
+		}
+
+		err = tx.Commit(ctx)
+		if err != nil {
+			return fmt.Errorf("commit transaction failed: %v", err)
+		}
+
+		log.Printf("Applied migration %s", version)
+	}
+
+	return nil
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+	id UUID PRIMARY KEY,
+	first_name TEXT NOT NULL,
+	last_name TEXT NOT NULL,
+	email TEXT NOT NULL UNIQUE,
+	hash TEXT,
+	role TEXT NOT NULL DEFAULT 'user'
+);
+
+CREATE TABLE IF NOT EXISTS articles (
+	id UUID PRIMARY KEY,
+	slug TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	description TEXT,
+	keywords TEXT,
+	body TEXT,
+	type TEXT,
+	format TEXT,
+	published TIMESTAMPTZ
+);
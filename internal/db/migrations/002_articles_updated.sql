@@ -0,0 +1 @@
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS updated TIMESTAMPTZ NOT NULL DEFAULT now();
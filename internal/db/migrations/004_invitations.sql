@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS invitations (
+	id UUID PRIMARY KEY,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'user',
+	token_hash TEXT NOT NULL UNIQUE,
+	invited_by TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	accepted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS invitations_created_at_idx ON invitations (created_at DESC);
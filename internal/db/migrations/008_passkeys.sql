@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS passkeys (
+	id UUID PRIMARY KEY,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	credential_id BYTEA NOT NULL UNIQUE,
+	public_key BYTEA NOT NULL,
+	attestation_type TEXT NOT NULL DEFAULT '',
+	transports TEXT[] NOT NULL DEFAULT '{}',
+	aaguid BYTEA,
+	sign_count BIGINT NOT NULL DEFAULT 0,
+	backup_eligible BOOLEAN NOT NULL DEFAULT false,
+	backup_state BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS passkeys_user_id_idx ON passkeys (user_id);
@@ -0,0 +1,8 @@
+ALTER TABLE password_resets ADD COLUMN IF NOT EXISTS email TEXT;
+
+-- Unused links sent before the address was kept may have gone to one the
+-- account no longer has, so they are dropped
+DELETE FROM password_resets WHERE email IS NULL AND used_at IS NULL;
+UPDATE password_resets r SET email = u.email FROM users u WHERE u.id = r.user_id AND r.email IS NULL;
+
+ALTER TABLE password_resets ALTER COLUMN email SET NOT NULL;
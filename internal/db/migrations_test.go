@@ -0,0 +1,11 @@
+package db
+
+import "testing"
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate returned an error on an up to date schema: %v", err)
+	}
+}
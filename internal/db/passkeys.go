@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+var ErrPasskeyNotFound = errors.New("passkey not found")
+
+const passkeyColumns = `id, user_id, name, credential_id, public_key, attestation_type, transports,
+	aaguid, sign_count, backup_eligible, backup_state, created_at, last_used_at`
+
+func scanPasskey(row pgx.Row) (types.Passkey, error) {
+	var passkey types.Passkey
+	var signCount int64
+	err := row.Scan(&passkey.ID, &passkey.UserID, &passkey.Name, &passkey.CredentialID, &passkey.PublicKey,
+		&passkey.AttestationType, &passkey.Transports, &passkey.AAGUID, &signCount, &passkey.BackupEligible,
+		&passkey.BackupState, &passkey.CreatedAt, &passkey.LastUsedAt)
+	passkey.SignCount = uint32(signCount)
+	return passkey, err
+}
+
+// CreatePasskey stores a credential a user just registered and returns its
+// ID.
+func (db *DB) CreatePasskey(passkey types.Passkey) (string, error) {
+	id := uuid.New().String()
+	sql := `INSERT INTO passkeys (id, user_id, name, credential_id, public_key, attestation_type, transports,
+		aaguid, sign_count, backup_eligible, backup_state)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := db.DB.Exec(context.Background(), sql, id, passkey.UserID, passkey.Name, passkey.CredentialID,
+		passkey.PublicKey, passkey.AttestationType, passkey.Transports, passkey.AAGUID, int64(passkey.SignCount),
+		passkey.BackupEligible, passkey.BackupState)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	return id, nil
+}
+
+// FetchPasskeys returns a user's passkeys, oldest first.
+func (db *DB) FetchPasskeys(userID string) ([]types.Passkey, error) {
+	sql := "SELECT " + passkeyColumns + " FROM passkeys WHERE user_id=$1 ORDER BY created_at"
+	rows, err := db.DB.Query(context.Background(), sql, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var passkeys []types.Passkey
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan failed: %v", err)
+		}
+		passkeys = append(passkeys, passkey)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("rows iteration failed: %v", rows.Err())
+	}
+
+	return passkeys, nil
+}
+
+// UsePasskey records a sign in with the credential, saving the counter and
+// backup state the authenticator reported.
+func (db *DB) UsePasskey(credentialID []byte, signCount uint32, backupState bool) error {
+	sql := "UPDATE passkeys SET sign_count=$1, backup_state=$2, last_used_at=now() WHERE credential_id=$3"
+	tag, err := db.DB.Exec(context.Background(), sql, int64(signCount), backupState, credentialID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// DeletePasskey removes one of a user's passkeys, returning
+// ErrPasskeyNotFound if they have none with that ID.
+func (db *DB) DeletePasskey(userID, id string) error {
+	sql := "DELETE FROM passkeys WHERE id=$1 AND user_id=$2"
+	tag, err := db.DB.Exec(context.Background(), sql, id, userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPasskeyNotFound
+	}
+
+	return nil
+}
+
+// DeletePasskeys removes every passkey a user has.
+func (db *DB) DeletePasskeys(userID string) error {
+	_, err := db.DB.Exec(context.Background(), "DELETE FROM passkeys WHERE user_id=$1", userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func TestPasskeys(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+	other := createTestUser(t, db, "grace@example.com")
+
+	passkeyID, err := db.CreatePasskey(types.Passkey{
+		UserID:       id,
+		Name:         "Laptop",
+		CredentialID: []byte{1, 2, 3},
+		PublicKey:    []byte{4, 5, 6},
+		Transports:   []string{"internal", "hybrid"},
+		SignCount:    1,
+	})
+	if err != nil {
+		t.Fatalf("CreatePasskey returned an error: %v", err)
+	}
+
+	_, err = db.CreatePasskey(types.Passkey{UserID: other, Name: "Copy", CredentialID: []byte{1, 2, 3}, PublicKey: []byte{7}})
+	if err == nil {
+		t.Error("CreatePasskey accepted a credential ID that is already registered")
+	}
+
+	// The test user has no password, but FetchAuth still fills in the user.
+	auth, err := db.FetchAuth("ada@example.com")
+	if err != ErrNoCredentials || !auth.Passkey {
+		t.Errorf("FetchAuth = %+v, %v; want Passkey true and ErrNoCredentials", auth, err)
+	}
+
+	if err := db.UsePasskey([]byte{1, 2, 3}, 7, true); err != nil {
+		t.Fatalf("UsePasskey returned an error: %v", err)
+	}
+	if err := db.UsePasskey([]byte{9}, 7, true); err != ErrPasskeyNotFound {
+		t.Errorf("UsePasskey of an unknown credential = %v; want ErrPasskeyNotFound", err)
+	}
+
+	passkeys, err := db.FetchPasskeys(id)
+	if err != nil {
+		t.Fatalf("FetchPasskeys returned an error: %v", err)
+	}
+	if len(passkeys) != 1 {
+		t.Fatalf("FetchPasskeys returned %d passkeys; want 1", len(passkeys))
+	}
+	p := passkeys[0]
+	if p.ID != passkeyID || !bytes.Equal(p.PublicKey, []byte{4, 5, 6}) || len(p.Transports) != 2 ||
+		p.SignCount != 7 || !p.BackupState || !p.LastUsedAt.Valid {
+		t.Errorf("FetchPasskeys = %+v", p)
+	}
+
+	if err := db.DeletePasskey(other, passkeyID); err != ErrPasskeyNotFound {
+		t.Errorf("DeletePasskey by another user = %v; want ErrPasskeyNotFound", err)
+	}
+	if err := db.DeletePasskey(id, passkeyID); err != nil {
+		t.Fatalf("DeletePasskey returned an error: %v", err)
+	}
+
+	passkeys, _ = db.FetchPasskeys(id)
+	if len(passkeys) != 0 {
+		t.Errorf("FetchPasskeys after delete returned %d passkeys; want 0", len(passkeys))
+	}
+
+	db.CreatePasskey(types.Passkey{UserID: id, Name: "Phone", CredentialID: []byte{8}, PublicKey: []byte{9}})
+	db.CreatePasskey(types.Passkey{UserID: id, Name: "Key", CredentialID: []byte{10}, PublicKey: []byte{11}})
+	if err := db.DeletePasskeys(id); err != nil {
+		t.Fatalf("DeletePasskeys returned an error: %v", err)
+	}
+
+	passkeys, _ = db.FetchPasskeys(id)
+	if len(passkeys) != 0 {
+		t.Errorf("FetchPasskeys after DeletePasskeys returned %d passkeys; want 0", len(passkeys))
+	}
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+)
+
+// ErrResetInvalid is returned for a reset link that does not exist, has
+// expired or has already been used.
+var ErrResetInvalid = errors.New("reset link is invalid or has expired")
+
+// CreatePasswordReset stores a reset link sent to email for a user under the
+// hash of its token, replacing any earlier link the user has not used.
+func (db *DB) CreatePasswordReset(userID, email, tokenHash string, expiresAt time.Time) (string, error) {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM password_resets WHERE user_id=$1 AND used_at IS NULL", userID)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	resetID := uuid.New().String()
+	sql := "INSERT INTO password_resets (id, user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err = tx.Exec(ctx, sql, resetID, userID, email, tokenHash, expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return "", fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return resetID, nil
+}
+
+// RecentPasswordReset reports whether a reset link was sent to the user
+// after since.
+func (db *DB) RecentPasswordReset(userID string, since time.Time) (bool, error) {
+	var recent bool
+	sql := "SELECT EXISTS (SELECT 1 FROM password_resets WHERE user_id=$1 AND created_at > $2)"
+	err := db.DB.QueryRow(context.Background(), sql, userID, since).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("query failed: %v", err)
+	}
+
+	return recent, nil
+}
+
+// FetchPendingPasswordReset returns the user a reset link is for if it can
+// still be used. A link stops working once the user's email changes from
+// the address it was sent to.
+func (db *DB) FetchPendingPasswordReset(tokenHash string) (types.User, error) {
+	var user types.User
+	sql := `SELECT u.id, u.first_name, u.last_name, u.email, u.role, u.email_verified_at
+		FROM password_resets r JOIN users u ON u.id = r.user_id
+		WHERE r.token_hash=$1 AND r.used_at IS NULL AND r.expires_at > now() AND u.email = r.email`
+	err := db.DB.QueryRow(context.Background(), sql, tokenHash).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
+	if err == pgx.ErrNoRows {
+		return user, ErrResetInvalid
+	}
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	return user, nil
+}
+
+// ResetPassword uses a reset link to set its user's password and returns
+// the user. The link, and any other the user has, cannot be used again.
+func (db *DB) ResetPassword(tokenHash, password string) (types.User, error) {
+	ctx := context.Background()
+
+	user, err := db.FetchPendingPasswordReset(tokenHash)
+	if err != nil {
+		return user, err
+	}
+
+	hash, err := passwords.HashPassword(password)
+	if err != nil {
+		return user, fmt.Errorf("hash password failed: %v", err)
+	}
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return user, fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := `UPDATE password_resets r SET used_at = now() FROM users u
+		WHERE r.token_hash=$1 AND r.used_at IS NULL AND r.expires_at > now()
+			AND u.id = r.user_id AND u.email = r.email`
+	tag, err := tx.Exec(ctx, sql, tokenHash)
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return user, ErrResetInvalid
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE password_resets SET used_at = now() WHERE user_id=$1 AND used_at IS NULL", user.ID)
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	// The link was sent to the user's address, so it is verified too
+	sql = "UPDATE users SET hash=$1, email_verified_at = COALESCE(email_verified_at, now()) WHERE id=$2 AND email=$3"
+	tag, err = tx.Exec(ctx, sql, hash, user.ID, user.Email)
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return user, ErrResetInvalid
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return user, fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+)
+
+func TestResetPassword(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	if _, err := db.CreatePasswordReset(id, "ada@example.com", "first", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset returned an error: %v", err)
+	}
+	if _, err := db.CreatePasswordReset(id, "ada@example.com", "second", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset returned an error: %v", err)
+	}
+
+	if _, err := db.FetchPendingPasswordReset("first"); err != ErrResetInvalid {
+		t.Errorf("a replaced link is still usable: error = %v", err)
+	}
+
+	recent, err := db.RecentPasswordReset(id, time.Now().Add(-time.Minute))
+	if err != nil || !recent {
+		t.Errorf("RecentPasswordReset = %v, %v; want true", recent, err)
+	}
+
+	user, err := db.ResetPassword("second", "correct horse battery")
+	if err != nil {
+		t.Fatalf("ResetPassword returned an error: %v", err)
+	}
+	if user.ID != id {
+		t.Errorf("ResetPassword returned user %q; want %q", user.ID, id)
+	}
+
+	auth, err := db.FetchAuth("ada@example.com")
+	if err != nil {
+		t.Fatalf("FetchAuth returned an error: %v", err)
+	}
+	if !passwords.CheckPasswordHash("correct horse battery", auth.Hash) {
+		t.Errorf("the new password does not match the stored hash")
+	}
+
+	if _, err := db.ResetPassword("second", "another horse battery"); err != ErrResetInvalid {
+		t.Errorf("ResetPassword reused a link: error = %v", err)
+	}
+
+	if _, err := db.CreatePasswordReset(id, "ada@example.com", "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordReset returned an error: %v", err)
+	}
+	if _, err := db.ResetPassword("expired", "another horse battery"); err != ErrResetInvalid {
+		t.Errorf("ResetPassword used an expired link: error = %v", err)
+	}
+
+	// A link sent to an address the account no longer has
+	if _, err := db.CreatePasswordReset(id, "old@example.com", "moved", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("CreatePasswordReset returned an error: %v", err)
+	}
+	if _, err := db.FetchPendingPasswordReset("moved"); err != ErrResetInvalid {
+		t.Errorf("a link to another address is usable: error = %v", err)
+	}
+	if _, err := db.ResetPassword("moved", "another horse battery"); err != ErrResetInvalid {
+		t.Errorf("ResetPassword used a link to another address: error = %v", err)
+	}
+}
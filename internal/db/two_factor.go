@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+// FetchTwoFactor returns a user's TOTP setup with the number of recovery
+// codes they have left.
+func (db *DB) FetchTwoFactor(userID string) (types.TwoFactor, error) {
+	var twoFactor types.TwoFactor
+	var secret *string
+	sql := `SELECT totp_secret, totp_enabled_at, totp_last_counter,
+		(SELECT count(*) FROM recovery_codes WHERE user_id = users.id AND used_at IS NULL)
+		FROM users WHERE id=$1`
+	err := db.DB.QueryRow(context.Background(), sql, userID).Scan(&secret, &twoFactor.EnabledAt, &twoFactor.LastCounter, &twoFactor.RecoveryCodes)
+	if err != nil {
+		return twoFactor, fmt.Errorf("query failed: %v", err)
+	}
+
+	if secret != nil {
+		twoFactor.Secret = *secret
+	}
+
+	return twoFactor, nil
+}
+
+// EnableTwoFactor turns on TOTP for a user with secret and replaces their
+// recovery codes with codeHashes.
+func (db *DB) EnableTwoFactor(userID, secret string, codeHashes []string) error {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "UPDATE users SET totp_secret=$1, totp_enabled_at=now(), totp_last_counter=0 WHERE id=$2"
+	_, err = tx.Exec(ctx, sql, secret, userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	err = replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for codeHashes.
+func (db *DB) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = replaceRecoveryCodes(ctx, tx, userID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, codeHashes []string) error {
+	_, err := tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id=$1", userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		sql := "INSERT INTO recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)"
+		_, err := tx.Exec(ctx, sql, uuid.New().String(), userID, codeHash)
+		if err != nil {
+			return fmt.Errorf("query failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// UseTOTPCounter records that the code for counter was used, and reports
+// false if it or a later one already was.
+func (db *DB) UseTOTPCounter(userID string, counter int64) (bool, error) {
+	sql := "UPDATE users SET totp_last_counter=$1 WHERE id=$2 AND totp_last_counter < $1"
+	tag, err := db.DB.Exec(context.Background(), sql, counter, userID)
+	if err != nil {
+		return false, fmt.Errorf("query failed: %v", err)
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode spends a recovery code, reporting false if the user has
+// no unused code with that hash.
+func (db *DB) UseRecoveryCode(userID, codeHash string) (bool, error) {
+	sql := "UPDATE recovery_codes SET used_at=now() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL"
+	tag, err := db.DB.Exec(context.Background(), sql, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("query failed: %v", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
+
+// DisableTwoFactor turns off TOTP for a user and deletes their recovery
+// codes.
+func (db *DB) DisableTwoFactor(userID string) error {
+	ctx := context.Background()
+
+	tx, err := db.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "UPDATE users SET totp_secret=NULL, totp_enabled_at=NULL, totp_last_counter=0 WHERE id=$1"
+	_, err = tx.Exec(ctx, sql, userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, "DELETE FROM recovery_codes WHERE user_id=$1", userID)
+	if err != nil {
+		return fmt.Errorf("query failed: %v", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("commit transaction failed: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestTwoFactor(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	if err := db.EnableTwoFactor(id, "SECRET", []string{"one", "two"}); err != nil {
+		t.Fatalf("EnableTwoFactor returned an error: %v", err)
+	}
+
+	twoFactor, err := db.FetchTwoFactor(id)
+	if err != nil {
+		t.Fatalf("FetchTwoFactor returned an error: %v", err)
+	}
+	if twoFactor.Secret != "SECRET" || !twoFactor.EnabledAt.Valid || twoFactor.RecoveryCodes != 2 {
+		t.Errorf("FetchTwoFactor = %+v", twoFactor)
+	}
+
+	counters := []struct {
+		counter int64
+		ok      bool
+	}{
+		{100, true},
+		{100, false},
+		{99, false},
+		{101, true},
+	}
+	for _, c := range counters {
+		if ok, err := db.UseTOTPCounter(id, c.counter); err != nil || ok != c.ok {
+			t.Errorf("UseTOTPCounter(%d) = %v, %v; want %v", c.counter, ok, err, c.ok)
+		}
+	}
+
+	if ok, _ := db.UseRecoveryCode(id, "one"); !ok {
+		t.Errorf("UseRecoveryCode(one) = false; want true")
+	}
+	if ok, _ := db.UseRecoveryCode(id, "one"); ok {
+		t.Errorf("UseRecoveryCode(one) a second time = true; want false")
+	}
+	if ok, _ := db.UseRecoveryCode(id, "three"); ok {
+		t.Errorf("UseRecoveryCode(three) = true; want false")
+	}
+
+	twoFactor, _ = db.FetchTwoFactor(id)
+	if twoFactor.RecoveryCodes != 1 {
+		t.Errorf("RecoveryCodes = %d after using one; want 1", twoFactor.RecoveryCodes)
+	}
+
+	if err := db.DisableTwoFactor(id); err != nil {
+		t.Fatalf("DisableTwoFactor returned an error: %v", err)
+	}
+
+	twoFactor, _ = db.FetchTwoFactor(id)
+	if twoFactor.Secret != "" || twoFactor.EnabledAt.Valid || twoFactor.RecoveryCodes != 0 {
+		t.Errorf("after DisableTwoFactor FetchTwoFactor = %+v", twoFactor)
+	}
+}
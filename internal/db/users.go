@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -12,18 +13,21 @@ import (
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
 )
 
-func (db *DB) RegisterUser(user types.RegisterUser) error {
+// ErrUserNotFound is returned when no user matches a lookup.
+var ErrUserNotFound = errors.New("user not found")
 
-	userID := uuid.New()
+func (db *DB) RegisterUser(user types.RegisterUser) (string, error) {
+
+	userID := uuid.New().String()
 	hash, _ := passwords.HashPassword(user.Password)
 
 	sql := "INSERT INTO users (id, first_name, last_name, email, hash) VALUES ($1, $2, $3, $4, $5)"
 	_, err := db.DB.Exec(context.Background(), sql, userID, user.FirstName, user.LastName, user.Email, hash)
 	if err != nil {
-		return fmt.Errorf("query failed: %v", err)
+		return "", fmt.Errorf("query failed: %v", err)
 	}
 
-	return nil
+	return userID, nil
 }
 
 func (db *DB) CreateUser(user types.User) (string, error) {
@@ -40,7 +44,7 @@ func (db *DB) CreateUser(user types.User) (string, error) {
 }
 
 func (db *DB) FetchUsers() ([]types.User, error) {
-	sql := "SELECT id, first_name, last_name, email, role FROM users"
+	sql := "SELECT id, first_name, last_name, email, role, email_verified_at FROM users"
 	rows, err := db.DB.Query(context.Background(), sql)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %v", err)
@@ -50,7 +54,7 @@ func (db *DB) FetchUsers() ([]types.User, error) {
 	var users []types.User
 	for rows.Next() {
 		var user types.User
-		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role)
+		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
 		if err != nil {
 			return nil, fmt.Errorf("row scan failed: %v", err)
 		}
@@ -66,8 +70,23 @@ func (db *DB) FetchUsers() ([]types.User, error) {
 
 func (db *DB) FetchUserById(id string) (types.User, error) {
 	var user types.User
-	sql := "SELECT id, first_name, last_name, email, role FROM users WHERE id=$1"
-	err := db.DB.QueryRow(context.Background(), sql, id).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role)
+	sql := "SELECT id, first_name, last_name, email, role, email_verified_at FROM users WHERE id=$1"
+	err := db.DB.QueryRow(context.Background(), sql, id).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
+	if err != nil {
+		return user, fmt.Errorf("query failed: %v", err)
+	}
+
+	return user, nil
+}
+
+// FetchUserByEmail returns ErrUserNotFound when no user has the email.
+func (db *DB) FetchUserByEmail(email string) (types.User, error) {
+	var user types.User
+	sql := "SELECT id, first_name, last_name, email, role, email_verified_at FROM users WHERE email=$1"
+	err := db.DB.QueryRow(context.Background(), sql, email).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Role, &user.EmailVerifiedAt)
+	if err == pgx.ErrNoRows {
+		return user, ErrUserNotFound
+	}
 	if err != nil {
 		return user, fmt.Errorf("query failed: %v", err)
 	}
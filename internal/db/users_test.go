@@ -0,0 +1,259 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+)
+
+func createTestUser(t *testing.T, db *DB, email string) string {
+	t.Helper()
+
+	id, err := db.CreateUser(types.User{FirstName: "Ada", LastName: "Lovelace", Email: email, Role: "user"})
+	if err != nil {
+		t.Fatalf("CreateUser(%q) returned an error: %v", email, err)
+	}
+
+	return id
+}
+
+func TestCreateUser(t *testing.T) {
+	db := newTestDB(t)
+	createTestUser(t, db, "taken@example.com")
+
+	tests := []struct {
+		name    string
+		user    types.User
+		wantErr bool
+	}{
+		{"admin", types.User{FirstName: "Grace", LastName: "Hopper", Email: "grace@example.com", Role: "admin"}, false},
+		{"user", types.User{FirstName: "Alan", LastName: "Turing", Email: "alan@example.com", Role: "user"}, false},
+		{"duplicate email", types.User{FirstName: "Ada", LastName: "Lovelace", Email: "taken@example.com", Role: "user"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id, err := db.CreateUser(test.user)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CreateUser() error = %v; wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			got, err := db.FetchUserById(id)
+			if err != nil {
+				t.Fatalf("FetchUserById(%q) returned an error: %v", id, err)
+			}
+
+			test.user.ID = id
+			if got != test.user {
+				t.Errorf("FetchUserById(%q) = %+v; want %+v", id, got, test.user)
+			}
+		})
+	}
+}
+
+func TestRegisterUser(t *testing.T) {
+	db := newTestDB(t)
+
+	user := types.RegisterUser{
+		FirstName:       "Ada",
+		LastName:        "Lovelace",
+		Email:           "ada@example.com",
+		Password:        "correct horse battery",
+		ConfirmPassword: "correct horse battery",
+	}
+
+	if _, err := db.RegisterUser(user); err != nil {
+		t.Fatalf("RegisterUser returned an error: %v", err)
+	}
+
+	if _, err := db.RegisterUser(user); err == nil {
+		t.Fatalf("RegisterUser returned no error for a duplicate email")
+	}
+
+	users, err := db.FetchUsers()
+	if err != nil {
+		t.Fatalf("FetchUsers returned an error: %v", err)
+	}
+
+	if len(users) != 1 {
+		t.Fatalf("FetchUsers returned %d users; want 1", len(users))
+	}
+
+	if users[0].Role != "user" {
+		t.Errorf("registered user role = %q; want %q", users[0].Role, "user")
+	}
+}
+
+func TestFetchUsers(t *testing.T) {
+	db := newTestDB(t)
+
+	users, err := db.FetchUsers()
+	if err != nil {
+		t.Fatalf("FetchUsers returned an error: %v", err)
+	}
+	if len(users) != 0 {
+		t.Fatalf("FetchUsers returned %d users on an empty table; want 0", len(users))
+	}
+
+	createTestUser(t, db, "one@example.com")
+	createTestUser(t, db, "two@example.com")
+
+	users, err = db.FetchUsers()
+	if err != nil {
+		t.Fatalf("FetchUsers returned an error: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("FetchUsers returned %d users; want 2", len(users))
+	}
+}
+
+func TestFetchUserById(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{"existing", id, false},
+		{"missing", "00000000-0000-0000-0000-000000000000", true},
+		{"malformed", "not-a-uuid", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, err := db.FetchUserById(test.id)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FetchUserById(%q) error = %v; wantErr %v", test.id, err, test.wantErr)
+			}
+			if !test.wantErr && user.Email != "ada@example.com" {
+				t.Errorf("FetchUserById(%q).Email = %q; want %q", test.id, user.Email, "ada@example.com")
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	if err := db.DeleteUser(id); err != nil {
+		t.Fatalf("DeleteUser returned an error: %v", err)
+	}
+
+	if _, err := db.FetchUserById(id); err == nil {
+		t.Fatalf("FetchUserById found a deleted user")
+	}
+
+	// Deleting a user that does not exist is not an error.
+	if err := db.DeleteUser(id); err != nil {
+		t.Fatalf("DeleteUser returned an error for a missing user: %v", err)
+	}
+}
+
+func TestFetchEmail(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	tests := []struct {
+		name    string
+		column  string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"by email", "email", "ada@example.com", "ada@example.com", false},
+		{"by email missing", "email", "nobody@example.com", "", false},
+		{"by email wrong case", "email", "ADA@example.com", "", false},
+		{"by id", "id", id, "ada@example.com", false},
+		{"by id missing", "id", "00000000-0000-0000-0000-000000000000", "", false},
+		{"by id malformed", "id", "not-a-uuid", "", true},
+		{"by id empty", "id", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := db.fetchEmail(test.column, test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("fetchEmail(%q, %q) error = %v; wantErr %v", test.column, test.value, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("fetchEmail(%q, %q) = %q; want %q", test.column, test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestEmailExistsInDatabase(t *testing.T) {
+	db := newTestDB(t)
+	createTestUser(t, db, "ada@example.com")
+
+	tests := []struct {
+		email string
+		want  bool
+	}{
+		{"ada@example.com", true},
+		{"nobody@example.com", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.email, func(t *testing.T) {
+			if got := db.EmailExistsInDatabase(test.email); got != test.want {
+				t.Errorf("EmailExistsInDatabase(%q) = %v; want %v", test.email, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetExistingEmail(t *testing.T) {
+	db := newTestDB(t)
+	id := createTestUser(t, db, "ada@example.com")
+
+	got, err := db.GetExistingEmail(id)
+	if err != nil {
+		t.Fatalf("GetExistingEmail returned an error: %v", err)
+	}
+	if got != "ada@example.com" {
+		t.Errorf("GetExistingEmail(%q) = %q; want %q", id, got, "ada@example.com")
+	}
+}
+
+func TestUniqueEmail(t *testing.T) {
+	db := newTestDB(t)
+	adaID := createTestUser(t, db, "ada@example.com")
+	createTestUser(t, db, "grace@example.com")
+
+	validate := validator.New()
+	validate.RegisterValidation("uniqueEmail", db.UniqueEmail)
+
+	tests := []struct {
+		name  string
+		id    string
+		email string
+		want  bool
+	}{
+		{"new user with unused email", "", "alan@example.com", true},
+		{"new user with taken email", "", "ada@example.com", false},
+		{"existing user keeps own email", adaID, "ada@example.com", true},
+		{"existing user takes unused email", adaID, "ada@lovelace.example.com", true},
+		{"existing user takes another's email", adaID, "grace@example.com", false},
+		{"unknown user with unused email", "00000000-0000-0000-0000-000000000000", "alan@example.com", true},
+		{"malformed user id", "not-a-uuid", "alan@example.com", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user := types.User{ID: test.id, FirstName: "Ada", LastName: "Lovelace", Email: test.email, Role: "user"}
+			err := validate.Struct(user)
+			if got := err == nil; got != test.want {
+				t.Errorf("UniqueEmail(%q, %q) valid = %v; want %v (err: %v)", test.id, test.email, got, test.want, err)
+			}
+		})
+	}
+}
@@ -2,6 +2,10 @@ package types
 
 import (
 	"database/sql"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/render"
 )
 
 type Article struct {
@@ -12,6 +16,14 @@ type Article struct {
 	Keywords    sql.NullString `json:"keywords"`
 	Body        sql.NullString `json:"body"`
 	Published   sql.NullTime   `json:"published"`
-	Format      sql.NullString `json:"format"`
+	Format      sql.NullString `json:"format" validate:"articleFormat"`
 	Type        sql.NullString `json:"type"`
+	Updated     time.Time      `json:"updated"`
+}
+
+// ArticleFormat is a custom validation function ensuring an article's format
+// has a registered renderer. An empty format is allowed and renders as markdown.
+func ArticleFormat(fl validator.FieldLevel) bool {
+	format := SafeValue(fl.Field().Interface())
+	return format == "" || render.IsRegistered(render.Format(format))
 }
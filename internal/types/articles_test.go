@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestArticleFormat(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterValidation("articleFormat", ArticleFormat)
+
+	tests := []struct {
+		format string
+		valid  bool
+	}{
+		{"", true},
+		{"markdown", true},
+		{"html", true},
+		{"text", true},
+		{"asciidoc", false},
+		{"Markdown", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			article := Article{Title: "Hello", Format: TypeSqlNullString(test.format)}
+			err := validate.Struct(article)
+			if (err == nil) != test.valid {
+				t.Errorf("validate format %q: err = %v; want valid %v", test.format, err, test.valid)
+			}
+		})
+	}
+}
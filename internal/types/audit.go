@@ -0,0 +1,35 @@
+package types
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent records something done to a user, article or other target.
+// Actor is the email of the signed in user, or empty for events the system
+// raises itself such as a lockout. Before and After hold JSON snapshots of
+// the target when there are any.
+type AuditEvent struct {
+	ID         string          `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+}
+
+// AuditFilter narrows a listing of audit events. Empty fields match every
+// event; Actor ignores case. From and To bound CreatedAt, To exclusive.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
@@ -11,4 +11,22 @@ type AuthUser struct {
 	LastName  string `json:"last_name" validate:"required"`
 	Email     string `json:"email" validate:"required,email"`
 	Hash      string `db:"hash"`
+	Role      string `db:"role"`
+	// EmailVerified is false until the user follows a verification link.
+	EmailVerified bool `db:"email_verified"`
+	// TwoFactor is true once the user has set up an authenticator app.
+	TwoFactor bool `db:"two_factor"`
+	// Passkey is true when the user has registered at least one passkey.
+	Passkey bool `db:"passkey"`
+}
+
+// ResetPassword holds a new password chosen through a reset link, held to
+// the same rules as RegisterUser. The user's email and names are only there
+// to keep them out of the password.
+type ResetPassword struct {
+	Password        string `json:"password" validate:"required,password"`
+	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
+	Email           string `json:"-" validate:"-"`
+	FirstName       string `json:"-" validate:"-"`
+	LastName        string `json:"-" validate:"-"`
 }
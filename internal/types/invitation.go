@@ -0,0 +1,22 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Invitation lets the holder of its emailed link register Email with Role.
+type Invitation struct {
+	ID         string       `json:"id"`
+	Email      string       `json:"email" validate:"required,email,uniqueEmail"`
+	Role       string       `json:"role" validate:"required,oneof=admin author user"`
+	InvitedBy  string       `json:"invited_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+	ExpiresAt  time.Time    `json:"expires_at"`
+	AcceptedAt sql.NullTime `json:"accepted_at"`
+}
+
+// Pending reports whether the invitation can still be accepted.
+func (i Invitation) Pending() bool {
+	return !i.AcceptedAt.Valid && time.Now().Before(i.ExpiresAt)
+}
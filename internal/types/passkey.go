@@ -0,0 +1,25 @@
+package types
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Passkey is a WebAuthn credential a user registered to sign in with.
+// CredentialID and PublicKey come from the authenticator; SignCount is the
+// last signature counter it reported.
+type Passkey struct {
+	ID              string       `json:"id"`
+	UserID          string       `json:"user_id"`
+	Name            string       `json:"name" validate:"required,max=100"`
+	CredentialID    []byte       `json:"-"`
+	PublicKey       []byte       `json:"-"`
+	AttestationType string       `json:"-"`
+	Transports      []string     `json:"-"`
+	AAGUID          []byte       `json:"-"`
+	SignCount       uint32       `json:"-"`
+	BackupEligible  bool         `json:"-"`
+	BackupState     bool         `json:"-"`
+	CreatedAt       time.Time    `json:"created_at"`
+	LastUsedAt      sql.NullTime `json:"last_used_at"`
+}
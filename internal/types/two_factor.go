@@ -0,0 +1,12 @@
+package types
+
+import "database/sql"
+
+// TwoFactor is a user's TOTP setup. LastCounter is the period of the last
+// code accepted, so no code is accepted twice.
+type TwoFactor struct {
+	Secret        string
+	EnabledAt     sql.NullTime
+	LastCounter   int64
+	RecoveryCodes int
+}
@@ -1,18 +1,23 @@
 package types
 
+import "database/sql"
+
 type User struct {
 	ID        string `db:"id"`
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
 	Email     string `json:"email" validate:"required,email,uniqueEmail"`
-	Role      string `json:"role" validate:"required,oneof=admin user"`
+	Role      string `json:"role" validate:"required,oneof=admin author user"`
+	// EmailVerifiedAt is set once the user follows a link sent to Email. It
+	// is kept out of JSON, which the public API serves users as.
+	EmailVerifiedAt sql.NullTime `json:"-"`
 }
 
 type CreateUser struct {
 	FirstName string `json:"first_name" validate:"required"`
 	LastName  string `json:"last_name" validate:"required"`
 	Email     string `json:"email" validate:"required,email,uniqueEmail"`
-	Role      string `json:"role" validate:"required,oneof=admin user"`
+	Role      string `json:"role" validate:"required,oneof=admin author user"`
 }
 
 type RegisterUser struct {
@@ -20,6 +25,6 @@ type RegisterUser struct {
 	FirstName       string `json:"first_name" validate:"required"`
 	LastName        string `json:"last_name" validate:"required"`
 	Email           string `json:"email" validate:"required,email,uniqueEmail"`
-	Password        string `json:"password" validate:"required,min=12"`
+	Password        string `json:"password" validate:"required,password"`
 	ConfirmPassword string `json:"confirm_password" validate:"required,eqfield=Password"`
 }
@@ -0,0 +1,165 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/fileserver"
+)
+
+// Immutable is the Cache-Control sent with fingerprinted files. A changed
+// file gets a new name, so clients never need to revalidate.
+const Immutable = "public, max-age=31536000, immutable"
+
+// Manifest maps asset names to fingerprinted names. Names are relative to
+// the asset directory, e.g. "css/main.min.css" maps to
+// "css/main.min.1a2b3c4d5e.css".
+type Manifest struct {
+	prefix string
+	paths  map[string]string
+	files  map[string]string
+	server *fileserver.Server
+}
+
+// Load builds a manifest for the files in fsys, served under the URL
+// prefix. If fsys holds a manifest.json written by the asset build it is
+// used as is, otherwise every file is hashed. A missing directory gives an
+// empty manifest, so asset URLs are left as they are.
+func Load(fsys fs.FS, prefix string) (*Manifest, error) {
+	m := &Manifest{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		paths:  make(map[string]string),
+		files:  make(map[string]string),
+		server: fileserver.New(fsys, Immutable),
+	}
+
+	contents, err := fs.ReadFile(fsys, "manifest.json")
+	if err == nil {
+		var paths map[string]string
+		if err := json.Unmarshal(contents, &paths); err != nil {
+			return nil, fmt.Errorf("invalid asset manifest: %v", err)
+		}
+		for name, hashed := range paths {
+			m.add(name, hashed)
+		}
+		return m, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if _, err := fs.Stat(fsys, "."); errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		sum, err := hashFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		m.add(name, fingerprint(name, sum))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash assets: %v", err)
+	}
+
+	return m, nil
+}
+
+func (m *Manifest) add(name, hashed string) {
+	m.paths[name] = hashed
+	m.files[hashed] = name
+}
+
+// Path returns the fingerprinted URL for an asset URL such as
+// "/dist/css/main.min.css". URLs the manifest does not know are returned
+// unchanged.
+func (m *Manifest) Path(url string) string {
+	name := strings.TrimPrefix(url, m.prefix+"/")
+	hashed, ok := m.paths[name]
+	if !ok {
+		return url
+	}
+
+	return m.prefix + "/" + hashed
+}
+
+// Handler serves fingerprinted URLs with immutable caching and passes every
+// other request to next.
+func (m *Manifest) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hashed, ok := strings.CutPrefix(r.URL.Path, m.prefix+"/")
+		name, known := m.files[hashed]
+		if !ok || !known {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A manifest.json may point at files already written under their
+		// fingerprinted names.
+		if m.server.ServeFile(w, r, hashed) || m.server.ServeFile(w, r, name) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Set combines manifests for several URL prefixes, such as /dist and
+// /fonts.
+type Set []*Manifest
+
+// Path returns the fingerprinted URL from whichever manifest knows url, or
+// url unchanged.
+func (s Set) Path(url string) string {
+	for _, m := range s {
+		if hashed := m.Path(url); hashed != url {
+			return hashed
+		}
+	}
+	return url
+}
+
+// Handler serves every manifest's fingerprinted URLs and passes other
+// requests to next.
+func (s Set) Handler(next http.Handler) http.Handler {
+	for _, m := range s {
+		next = m.Handler(next)
+	}
+	return next
+}
+
+func hashFile(fsys fs.FS, name string) (string, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:10], nil
+}
+
+// fingerprint inserts sum before the extension of name.
+func fingerprint(name, sum string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + sum + ext
+}
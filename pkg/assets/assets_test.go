@@ -0,0 +1,172 @@
+package assets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func writeAsset(t *testing.T, dir, name, contents string) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadHashesFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "css/main.min.css", "body{}")
+	writeAsset(t, dir, "js/main.min.js", "var a;")
+
+	m, err := Load(os.DirFS(dir), "/dist/")
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/dist/css/main.min.css", `^/dist/css/main\.min\.[0-9a-f]{10}\.css$`},
+		{"/dist/js/main.min.js", `^/dist/js/main\.min\.[0-9a-f]{10}\.js$`},
+		{"/dist/css/missing.css", `^/dist/css/missing\.css$`},
+		{"/fonts/fonts.css", `^/fonts/fonts\.css$`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			got := m.Path(test.url)
+			if !regexp.MustCompile(test.want).MatchString(got) {
+				t.Errorf("Path(%q) = %q; want match for %s", test.url, got, test.want)
+			}
+		})
+	}
+
+	before := m.Path("/dist/css/main.min.css")
+	writeAsset(t, dir, "css/main.min.css", "body{color:red}")
+
+	m, err = Load(os.DirFS(dir), "/dist")
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if after := m.Path("/dist/css/main.min.css"); after == before {
+		t.Errorf("Path did not change after the file changed: %q", after)
+	}
+	if m.Path("/dist/js/main.min.js") == "/dist/js/main.min.js" {
+		t.Errorf("Path did not fingerprint an unchanged file")
+	}
+}
+
+func TestLoadReadsManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "manifest.json", `{"css/main.min.css": "css/main.min.abc123.css"}`)
+	writeAsset(t, dir, "css/main.min.abc123.css", "body{}")
+
+	m, err := Load(os.DirFS(dir), "/dist")
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	if got, want := m.Path("/dist/css/main.min.css"), "/dist/css/main.min.abc123.css"; got != want {
+		t.Errorf("Path = %q; want %q", got, want)
+	}
+
+	writeAsset(t, dir, "manifest.json", `{not json`)
+	if _, err := Load(os.DirFS(dir), "/dist"); err == nil {
+		t.Errorf("Load returned no error for an invalid manifest")
+	}
+}
+
+func TestLoadMissingDir(t *testing.T) {
+	m, err := Load(os.DirFS(filepath.Join(t.TempDir(), "missing")), "/dist")
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if got := m.Path("/dist/css/main.min.css"); got != "/dist/css/main.min.css" {
+		t.Errorf("Path = %q; want it unchanged", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeAsset(t, dir, "css/main.min.css", "body{}")
+
+	m, err := Load(os.DirFS(dir), "/dist")
+	if err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		status       int
+		cacheControl string
+	}{
+		{"fingerprinted", m.Path("/dist/css/main.min.css"), http.StatusOK, Immutable},
+		{"plain name", "/dist/css/main.min.css", http.StatusNotFound, ""},
+		{"stale fingerprint", "/dist/css/main.min.0000000000.css", http.StatusNotFound, ""},
+		{"outside the directory", "/dist/../assets.go", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			m.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest("GET", test.url, nil))
+
+			if rec.Code != test.status {
+				t.Fatalf("GET %s status = %d; want %d", test.url, rec.Code, test.status)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != test.cacheControl {
+				t.Errorf("GET %s Cache-Control = %q; want %q", test.url, got, test.cacheControl)
+			}
+			if test.status == http.StatusOK && rec.Body.String() != "body{}" {
+				t.Errorf("GET %s body = %q; want %q", test.url, rec.Body.String(), "body{}")
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	dist, fonts := t.TempDir(), t.TempDir()
+	writeAsset(t, dist, "css/main.min.css", "body{}")
+	writeAsset(t, fonts, "fonts.css", "@font-face{}")
+
+	var set Set
+	for prefix, dir := range map[string]string{"/dist": dist, "/fonts": fonts} {
+		m, err := Load(os.DirFS(dir), prefix)
+		if err != nil {
+			t.Fatalf("Load returned an error: %v", err)
+		}
+		set = append(set, m)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/dist/css/main.min.css", `^/dist/css/main\.min\.[0-9a-f]{10}\.css$`},
+		{"/fonts/fonts.css", `^/fonts/fonts\.[0-9a-f]{10}\.css$`},
+		{"/fontawesome/css/all.min.css", `^/fontawesome/css/all\.min\.css$`},
+	}
+
+	for _, test := range tests {
+		if got := set.Path(test.url); !regexp.MustCompile(test.want).MatchString(got) {
+			t.Errorf("Path(%q) = %q; want match for %s", test.url, got, test.want)
+		}
+	}
+
+	handler := set.Handler(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", set.Path("/fonts/fonts.css"), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "@font-face{}" {
+		t.Errorf("fingerprinted font CSS = %d %q; want 200 and the file", rec.Code, rec.Body.String())
+	}
+}
@@ -5,12 +5,18 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/boj/redistore"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
 )
 
 type AuthMiddleware struct {
 	SessionStore *redistore.RediStore
+	Sessions     *Sessions
+
+	// Role looks up the signed in user's role for RoleRequired.
+	Role func(email string) (string, error)
 }
 
 func (m *AuthMiddleware) AuthRequired(next http.Handler) http.Handler {
@@ -40,6 +46,22 @@ func (m *AuthMiddleware) AuthRequired(next http.Handler) http.Handler {
 			return
 		}
 
+		// A session ended from elsewhere is refused even if the store still
+		// has it, such as when this request raced the logout
+		if m.Sessions != nil {
+			email, _ := session.Values["user_email"].(string)
+			active, err := m.Sessions.Touch(email, session.ID, r.UserAgent(), throttle.ClientIP(r), time.Duration(sessionExpiry)*time.Second)
+			if err != nil {
+				log.Printf("Failed to record session: %v", err)
+			} else if !active {
+				log.Printf("Session for %s was ended. Redirecting to login.", email)
+				session.Options.MaxAge = -1
+				session.Save(r, w)
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+		}
+
 		// Reset session expiration
 		session.Options.MaxAge = sessionExpiry
 
@@ -50,6 +72,41 @@ func (m *AuthMiddleware) AuthRequired(next http.Handler) http.Handler {
 			http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
 			return
 		}
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RoleRequired refuses signed in users whose role is not one of roles. It
+// goes after AuthRequired. The role is looked up on every request, so a
+// change takes effect straight away.
+func (m *AuthMiddleware) RoleRequired(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := m.SessionStore.Get(r, "com-jasonsnider-go")
+			if err != nil {
+				log.Printf("Failed to get session: %v", err)
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+
+			email, _ := session.Values["user_email"].(string)
+			role, err := m.Role(email)
+			if err != nil {
+				log.Printf("Failed to look up role for %s: %v", email, err)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			log.Printf("Refused %s %s to %s with role %q", r.Method, r.URL.Path, email, role)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		})
+	}
+}
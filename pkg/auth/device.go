@@ -0,0 +1,49 @@
+package auth
+
+import "strings"
+
+// Device describes the browser and system in a User-Agent header, such as
+// "Firefox on Windows", for people looking over where they are signed in.
+// It only knows the common ones and falls back to "Unknown device".
+func Device(userAgent string) string {
+	browser := match(userAgent, [][2]string{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	})
+
+	system := match(userAgent, [][2]string{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	})
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return "Unknown device"
+}
+
+// match returns the name paired with the first token found in s.
+func match(s string, names [][2]string) string {
+	for _, name := range names {
+		if strings.Contains(s, name[0]) {
+			return name[1]
+		}
+	}
+	return ""
+}
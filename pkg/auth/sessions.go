@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Sessions remembers which sessions each user signed in with, and from
+// where, so they can be listed and ended one at a time or all at once, such
+// as after a password reset. It shares the session store's Redis pool and
+// must know the store's key prefix.
+type Sessions struct {
+	Pool      *redis.Pool
+	KeyPrefix string
+}
+
+// Session is one signed in browser. Handle names it in pages and forms, so
+// the store's own session ID is never shown.
+type Session struct {
+	Handle    string
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	LastSeen  time.Time
+}
+
+// NewSessions returns Sessions for a redistore using its default prefix.
+func NewSessions(pool *redis.Pool) *Sessions {
+	return &Sessions{Pool: pool, KeyPrefix: "session_"}
+}
+
+func userKey(email string) string {
+	return "user_sessions:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func infoKey(id string) string {
+	return "session_info:" + id
+}
+
+// Handle returns the name shown for session id.
+func Handle(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Add records session id for a user who just signed in from userAgent and
+// ip. The record lasts as long as maxAge and is extended by Touch.
+func (s *Sessions) Add(email, id, userAgent, ip string, maxAge time.Duration) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	now := time.Now().Unix()
+
+	conn.Send("MULTI")
+	conn.Send("SADD", userKey(email), id)
+	conn.Send("EXPIRE", userKey(email), int(maxAge.Seconds()))
+	conn.Send("HSET", infoKey(id), "user_agent", userAgent, "ip", ip, "created", now, "seen", now)
+	conn.Send("EXPIRE", infoKey(id), int(maxAge.Seconds()))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// Touch notes that session id was used again and keeps its record alive
+// for another maxAge. It reports false, changing nothing, when the session
+// has been ended, so a request racing the logout cannot bring it back.
+func (s *Sessions) Touch(email, id, userAgent, ip string, maxAge time.Duration) (bool, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	active, err := redis.Bool(conn.Do("SISMEMBER", userKey(email), id))
+	if err != nil || !active {
+		return false, err
+	}
+
+	conn.Send("MULTI")
+	conn.Send("EXPIRE", userKey(email), int(maxAge.Seconds()))
+	conn.Send("HSETNX", infoKey(id), "created", time.Now().Unix())
+	conn.Send("HSET", infoKey(id), "user_agent", userAgent, "ip", ip, "seen", time.Now().Unix())
+	conn.Send("EXPIRE", infoKey(id), int(maxAge.Seconds()))
+	_, err = conn.Do("EXEC")
+	return true, err
+}
+
+// List returns the user's sessions, most recently used first. Sessions the
+// store has already expired are forgotten on the way.
+func (s *Sessions) List(email string) ([]Session, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("SMEMBERS", userKey(email)))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Session
+	for _, id := range ids {
+		exists, err := redis.Bool(conn.Do("EXISTS", s.KeyPrefix+id))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			_, err = conn.Do("SREM", userKey(email), id)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		info, err := redis.StringMap(conn.Do("HGETALL", infoKey(id)))
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, Session{
+			Handle:    Handle(id),
+			UserAgent: info["user_agent"],
+			IP:        info["ip"],
+			CreatedAt: unix(info["created"]),
+			LastSeen:  unix(info["seen"]),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].LastSeen.After(list[j].LastSeen)
+	})
+
+	return list, nil
+}
+
+// Remove forgets session id, for a user who logged out.
+func (s *Sessions) Remove(email, id string) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("SREM", userKey(email), id)
+	conn.Send("DEL", infoKey(id))
+	_, err := conn.Do("EXEC")
+	return err
+}
+
+// End deletes the user's session named handle. It reports false when they
+// have no such session.
+func (s *Sessions) End(email, handle string) (bool, error) {
+	ended, err := s.end(email, func(id string) bool { return Handle(id) == handle })
+	return ended > 0, err
+}
+
+// EndOthers deletes every session the user has except id, the one they
+// are using.
+func (s *Sessions) EndOthers(email, id string) (int, error) {
+	return s.end(email, func(other string) bool { return other != id })
+}
+
+// EndAll deletes every session the user has.
+func (s *Sessions) EndAll(email string) error {
+	_, err := s.end(email, func(string) bool { return true })
+	return err
+}
+
+// end deletes the user's sessions that match and returns how many.
+func (s *Sessions) end(email string, match func(id string) bool) (int, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("SMEMBERS", userKey(email)))
+	if err != nil {
+		return 0, err
+	}
+
+	var members, keys []interface{}
+	for _, id := range ids {
+		if match(id) {
+			members = append(members, id)
+			keys = append(keys, s.KeyPrefix+id, infoKey(id))
+		}
+	}
+	if len(members) == 0 {
+		return 0, nil
+	}
+
+	conn.Send("MULTI")
+	conn.Send("SREM", append([]interface{}{userKey(email)}, members...)...)
+	conn.Send("DEL", keys...)
+	_, err = conn.Do("EXEC")
+	return len(members), err
+}
+
+func unix(s string) time.Time {
+	var t time.Time
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		t = time.Unix(sec, 0)
+	}
+	return t
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+
+	// Touch a so b becomes the least recently used entry.
+	c.Get("a")
+	c.Set("c", []byte("3"))
+
+	tests := []struct {
+		key   string
+		found bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			if _, ok := c.Get(test.key); ok != test.found {
+				t.Errorf("Get(%q) found = %v; want %v", test.key, ok, test.found)
+			}
+		})
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d; want 2", c.Len())
+	}
+}
+
+func TestLRUSetReplacesAndDeleteRemoves(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", []byte("1"))
+	c.Set("a", []byte("2"))
+
+	if value, _ := c.Get("a"); string(value) != "2" {
+		t.Errorf("Get(a) = %q; want %q", value, "2")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d; want 1", c.Len())
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) found a deleted entry")
+	}
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"log"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis is a Store backed by a Redis connection pool, such as the one owned
+// by the session store. Keys are namespaced by prefix and expire after ttl.
+type Redis struct {
+	Pool   *redis.Pool
+	Prefix string
+	TTL    time.Duration
+}
+
+func (c *Redis) Get(key string) ([]byte, bool) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	value, err := redis.Bytes(conn.Do("GET", c.Prefix+key))
+	if err != nil {
+		if err != redis.ErrNil {
+			log.Printf("Redis cache get failed: %v", err)
+		}
+		return nil, false
+	}
+
+	return value, true
+}
+
+func (c *Redis) Set(key string, value []byte) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	var err error
+	if c.TTL > 0 {
+		_, err = conn.Do("SET", c.Prefix+key, value, "EX", int(c.TTL.Seconds()))
+	} else {
+		_, err = conn.Do("SET", c.Prefix+key, value)
+	}
+	if err != nil {
+		log.Printf("Redis cache set failed: %v", err)
+	}
+}
+
+func (c *Redis) Delete(key string) {
+	conn := c.Pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", c.Prefix+key)
+	if err != nil {
+		log.Printf("Redis cache delete failed: %v", err)
+	}
+}
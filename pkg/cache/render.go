@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RenderCache holds rendered pages so they can be served without touching
+// the database or re-rendering. Pages are stored under their article's ID and
+// updated timestamp, and looked up through an index keyed by request path.
+// An optional remote Store, such as Redis, backs the in-process LRU and is
+// shared by every instance. Its id: entry is the current revision, checked
+// on every hit, so a page invalidated by one instance is not served from
+// another's LRU.
+//
+// Keys:
+//
+//	path:<path> -> <id>:<updated>
+//	paths:<id>  -> the paths rendered from the article, one per line
+//	id:<id>     -> <id>:<updated>
+//	page:<id>:<updated> -> rendered page
+type RenderCache struct {
+	local  *LRU
+	remote Store
+}
+
+// NewRenderCache returns a RenderCache keeping up to size entries in process.
+// remote may be nil.
+func NewRenderCache(size int, remote Store) *RenderCache {
+	return &RenderCache{
+		local:  NewLRU(size),
+		remote: remote,
+	}
+}
+
+// Get returns the page rendered for path, if any, along with the updated
+// timestamp of the article it was rendered from.
+func (c *RenderCache) Get(path string) ([]byte, time.Time, bool) {
+	key, ok := c.get("path:" + path)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	id, nanos, _ := strings.Cut(string(key), ":")
+	if c.remote != nil {
+		current, ok := c.remote.Get("id:" + id)
+		if !ok || !bytes.Equal(current, key) {
+			c.local.Delete("path:" + path)
+			return nil, time.Time{}, false
+		}
+	}
+
+	page, ok := c.get("page:" + string(key))
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	var updated time.Time
+	if n, err := strconv.ParseInt(nanos, 10, 64); err == nil {
+		updated = time.Unix(0, n)
+	}
+
+	return page, updated, true
+}
+
+// Set stores the page rendered for path from the given revision of an
+// article.
+func (c *RenderCache) Set(path, id string, updated time.Time, page []byte) {
+	key := []byte(id + ":" + strconv.FormatInt(updated.UnixNano(), 10))
+
+	c.set("page:"+string(key), page)
+	c.set("id:"+id, key)
+	c.set("path:"+path, key)
+
+	paths, _ := c.get("paths:" + id)
+	for _, p := range strings.Split(string(paths), "\n") {
+		if p == path {
+			return
+		}
+	}
+	c.set("paths:"+id, []byte(string(paths)+path+"\n"))
+}
+
+// Invalidate drops every page rendered from the article with the given ID,
+// along with the paths that led to them.
+func (c *RenderCache) Invalidate(id string) {
+	key, ok := c.get("id:" + id)
+	if ok {
+		c.delete("page:" + string(key))
+	}
+
+	paths, _ := c.get("paths:" + id)
+	for _, path := range strings.Split(string(paths), "\n") {
+		if path != "" {
+			c.delete("path:" + path)
+		}
+	}
+
+	c.delete("paths:" + id)
+	c.delete("id:" + id)
+}
+
+func (c *RenderCache) get(key string) ([]byte, bool) {
+	if value, ok := c.local.Get(key); ok {
+		return value, true
+	}
+
+	if c.remote == nil {
+		return nil, false
+	}
+
+	value, ok := c.remote.Get(key)
+	if ok {
+		c.local.Set(key, value)
+	}
+	return value, ok
+}
+
+func (c *RenderCache) set(key string, value []byte) {
+	c.local.Set(key, value)
+	if c.remote != nil {
+		c.remote.Set(key, value)
+	}
+}
+
+func (c *RenderCache) delete(key string) {
+	c.local.Delete(key)
+	if c.remote != nil {
+		c.remote.Delete(key)
+	}
+}
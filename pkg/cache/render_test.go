@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderCache(t *testing.T) {
+	updated := time.Date(2024, 9, 28, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		remote Store
+	}{
+		{"local only", nil},
+		{"with remote", NewLRU(100)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewRenderCache(100, test.remote)
+
+			if _, _, ok := c.Get("/articles/hello"); ok {
+				t.Fatalf("Get found a page in an empty cache")
+			}
+
+			c.Set("/articles/hello", "id-1", updated, []byte("v1"))
+			page, pageUpdated, ok := c.Get("/articles/hello")
+			if !ok || string(page) != "v1" {
+				t.Fatalf("Get = %q, %v; want %q, true", page, ok, "v1")
+			}
+			if !pageUpdated.Equal(updated) {
+				t.Errorf("Get updated = %v; want %v", pageUpdated, updated)
+			}
+
+			c.Invalidate("id-1")
+			if _, _, ok := c.Get("/articles/hello"); ok {
+				t.Fatalf("Get found a page after Invalidate")
+			}
+
+			c.Set("/articles/hello", "id-1", updated.Add(time.Minute), []byte("v2"))
+			if page, _, ok := c.Get("/articles/hello"); !ok || string(page) != "v2" {
+				t.Fatalf("Get = %q, %v; want %q, true", page, ok, "v2")
+			}
+
+			// Invalidating another article leaves this one alone.
+			c.Invalidate("id-2")
+			if _, _, ok := c.Get("/articles/hello"); !ok {
+				t.Fatalf("Get missed after invalidating a different article")
+			}
+		})
+	}
+}
+
+func TestRenderCacheFallsBackToRemote(t *testing.T) {
+	remote := NewLRU(100)
+	updated := time.Date(2024, 9, 28, 12, 0, 0, 0, time.UTC)
+
+	// A page rendered by another instance is found through the remote store.
+	NewRenderCache(100, remote).Set("/articles/hello", "id-1", updated, []byte("shared"))
+
+	c := NewRenderCache(100, remote)
+	if page, _, ok := c.Get("/articles/hello"); !ok || string(page) != "shared" {
+		t.Fatalf("Get = %q, %v; want %q, true", page, ok, "shared")
+	}
+}
+
+func TestRenderCacheInvalidatesOtherInstances(t *testing.T) {
+	remote := NewLRU(100)
+	updated := time.Date(2024, 9, 28, 12, 0, 0, 0, time.UTC)
+
+	a := NewRenderCache(100, remote)
+	b := NewRenderCache(100, remote)
+
+	a.Set("/articles/hello", "id-1", updated, []byte("v1"))
+	a.Set("/hello", "id-1", updated, []byte("v1"))
+	if _, _, ok := b.Get("/articles/hello"); !ok {
+		t.Fatalf("Get missed a page set by another instance")
+	}
+
+	// b now holds the page in its own LRU; a's invalidation must still reach it.
+	a.Invalidate("id-1")
+	if _, _, ok := b.Get("/articles/hello"); ok {
+		t.Errorf("Get found a page another instance invalidated")
+	}
+
+	for _, key := range []string{"path:/articles/hello", "path:/hello", "paths:id-1", "id:id-1"} {
+		if _, ok := remote.Get(key); ok {
+			t.Errorf("remote still holds %s after Invalidate", key)
+		}
+	}
+
+	b.Set("/articles/hello", "id-1", updated.Add(time.Minute), []byte("v2"))
+	if page, _, ok := a.Get("/articles/hello"); !ok || string(page) != "v2" {
+		t.Fatalf("Get = %q, %v; want %q, true", page, ok, "v2")
+	}
+}
@@ -0,0 +1,282 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// DefaultMinSize is the smallest body worth compressing. Anything shorter
+// usually grows once the encoding overhead is added.
+const DefaultMinSize = 1024
+
+// types are the compressible media types outside text/*.
+var types = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/rss+xml":    true,
+	"application/atom+xml":   true,
+	"application/javascript": true,
+	"image/svg+xml":          true,
+}
+
+type encoder interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(io.Writer)
+}
+
+// encodings lists the supported codings in order of preference.
+var encodings = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"br", &sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression)
+	}}},
+	{"gzip", &sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}}},
+}
+
+// Compressor compresses responses for clients that accept brotli or gzip.
+type Compressor struct {
+	MinSize int
+}
+
+// New returns a Compressor that leaves bodies shorter than minSize alone.
+func New(minSize int) *Compressor {
+	return &Compressor{MinSize: minSize}
+}
+
+// Handler compresses responses with the DefaultMinSize. It can be passed
+// straight to a mux router's Use.
+func Handler(next http.Handler) http.Handler {
+	return New(DefaultMinSize).Handler(next)
+}
+
+// Handler compresses HTML, JSON, XML and text responses from next.
+// Responses that already carry a Content-Encoding, range requests and HEAD
+// requests are passed through untouched.
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Without an accepted coding the body is left alone, but still
+		// varies by Accept-Encoding so shared caches keep the copies apart
+		coding := negotiate(r.Header.Get("Accept-Encoding"))
+
+		cw := &writer{ResponseWriter: w, minSize: c.MinSize, coding: coding}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// AcceptEncodings parses an Accept-Encoding header into the set of codings
+// with a non-zero quality.
+func AcceptEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for coding, q := range qualities(header) {
+		accepted[coding] = q > 0
+	}
+	return accepted
+}
+
+// qualities parses an Accept-Encoding header into each coding's quality.
+func qualities(header string) map[string]float64 {
+	q := make(map[string]float64)
+
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q[coding] = 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					q[coding] = parsed
+				}
+			}
+		}
+	}
+
+	return q
+}
+
+// negotiate returns the index of the coding the client gives the highest
+// quality, preferring the order of encodings between equals, or -1 if it
+// accepts none.
+func negotiate(header string) int {
+	q := qualities(header)
+
+	best, bestQ := -1, 0.0
+	for i, e := range encodings {
+		if q[e.name] > bestQ {
+			best, bestQ = i, q[e.name]
+		}
+	}
+
+	return best
+}
+
+// compressible reports whether a Content-Type is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(mediaType, "text/") || types[mediaType]
+}
+
+// writer buffers the start of a response until it knows whether the body
+// is long enough to compress, then either streams it through an encoder or
+// writes it as is.
+type writer struct {
+	http.ResponseWriter
+	minSize int
+	coding  int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *writer) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+
+	// Informational responses come before the real one.
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+
+	// Responses without a body are never compressed.
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush sends whatever has been written so far, compressing it if the
+// content allows, so streamed responses are not held back.
+func (w *writer) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.decide(true)
+	}
+
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide writes the header, compressing the body when large is true and
+// the response allows it, then sends anything buffered.
+func (w *writer) decide(large bool) error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Add("Vary", "Accept-Encoding")
+
+		if large && w.coding >= 0 {
+			e := encodings[w.coding]
+			w.enc = e.pool.Get().(encoder)
+			w.enc.Reset(w.ResponseWriter)
+
+			header.Set("Content-Encoding", e.name)
+			header.Del("Content-Length")
+
+			// The compressed bytes differ from the ones the ETag was
+			// computed from.
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+
+	return err
+}
+
+// close finishes the response once the handler returns.
+func (w *writer) close() {
+	if !w.decided {
+		if w.status == 0 {
+			return
+		}
+		w.decide(false)
+	}
+
+	if w.enc != nil {
+		w.enc.Close()
+		encodings[w.coding].pool.Put(w.enc)
+		w.enc = nil
+	}
+}
@@ -0,0 +1,215 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+var long = strings.Repeat("<p>compress me</p>", 100)
+
+func serve(t *testing.T, req *http.Request, next http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	New(DefaultMinSize).Handler(next).ServeHTTP(rec, req)
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var r io.Reader = rec.Body
+	switch rec.Header().Get("Content-Encoding") {
+	case "gzip":
+		gz, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("invalid gzip body: %v", err)
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(rec.Body)
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unable to decode body: %v", err)
+	}
+	return string(body)
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		rangeHeader    string
+		contentType    string
+		encoding       string
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip html", "GET", "gzip", "", "text/html; charset=utf-8", "", long, "gzip"},
+		{"brotli preferred", "GET", "gzip, br", "", "text/html; charset=utf-8", "", long, "br"},
+		{"higher quality wins", "GET", "gzip;q=1, br;q=0.1", "", "text/html; charset=utf-8", "", long, "gzip"},
+		{"json", "GET", "gzip", "", "application/json", "", long, "gzip"},
+		{"rss feed", "GET", "br", "", "application/rss+xml", "", long, "br"},
+		{"sniffed html", "GET", "gzip", "", "", "", long, "gzip"},
+		{"not accepted", "GET", "", "", "text/html; charset=utf-8", "", long, ""},
+		{"refused", "GET", "gzip;q=0", "", "text/html; charset=utf-8", "", long, ""},
+		{"too small", "GET", "gzip", "", "text/html; charset=utf-8", "", "<p>short</p>", ""},
+		{"image", "GET", "gzip", "", "image/png", "", long, ""},
+		{"already encoded", "GET", "gzip", "", "text/css", "br", long, "br"},
+		{"range", "GET", "gzip", "bytes=0-10", "text/html; charset=utf-8", "", long, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/", nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			if test.rangeHeader != "" {
+				req.Header.Set("Range", test.rangeHeader)
+			}
+
+			rec := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+				if test.contentType != "" {
+					w.Header().Set("Content-Type", test.contentType)
+				}
+				if test.encoding != "" {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				// Write in pieces so buffering across writes is covered.
+				io.WriteString(w, test.body[:5])
+				io.WriteString(w, test.body[5:])
+			})
+
+			if got := rec.Header().Get("Content-Encoding"); got != test.wantEncoding {
+				t.Fatalf("Content-Encoding = %q; want %q", got, test.wantEncoding)
+			}
+			if test.encoding != "" {
+				return
+			}
+			if got := decode(t, rec); got != test.body {
+				t.Errorf("decoded body = %q; want %q", got, test.body)
+			}
+		})
+	}
+}
+
+func TestHandlerHeaders(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Length", "1800")
+		w.Header().Set("ETag", `"abc"`)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, long)
+	})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusCreated)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("Content-Length = %q; want it removed", got)
+	}
+	if got := rec.Header().Get("ETag"); got != `W/"abc"` {
+		t.Errorf("ETag = %q; want %q", got, `W/"abc"`)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q; want %q", got, "Accept-Encoding")
+	}
+}
+
+func TestHandlerVaryWithoutEncoding(t *testing.T) {
+	rec := serve(t, httptest.NewRequest("GET", "/", nil), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, long)
+	})
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q; want none", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q; want %q", got, "Accept-Encoding")
+	}
+}
+
+func TestHandlerWithoutBody(t *testing.T) {
+	tests := []int{http.StatusNotModified, http.StatusNoContent, http.StatusFound}
+
+	for _, status := range tests {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			rec := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"abc"`)
+				w.WriteHeader(status)
+			})
+
+			if rec.Code != status {
+				t.Errorf("status = %d; want %d", rec.Code, status)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q; want none", got)
+			}
+			if got := rec.Header().Get("ETag"); got != `"abc"` {
+				t.Errorf("ETag = %q; want it unchanged", got)
+			}
+		})
+	}
+}
+
+func TestHandlerFlush(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	rec := serve(t, req, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, " second")
+	})
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q; want %q", got, "gzip")
+	}
+	if got := decode(t, rec); got != "first second" {
+		t.Errorf("decoded body = %q; want %q", got, "first second")
+	}
+}
+func TestAcceptEncodings(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]bool
+	}{
+		{"", map[string]bool{}},
+		{"gzip", map[string]bool{"gzip": true}},
+		{"gzip, deflate, br", map[string]bool{"gzip": true, "deflate": true, "br": true}},
+		{"br;q=0, GZIP;q=0.5", map[string]bool{"br": false, "gzip": true}},
+		{"br; q=0.0", map[string]bool{"br": false}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			got := AcceptEncodings(test.header)
+			if len(got) != len(test.want) {
+				t.Fatalf("AcceptEncodings(%q) = %v; want %v", test.header, got, test.want)
+			}
+			for coding, want := range test.want {
+				if got[coding] != want {
+					t.Errorf("AcceptEncodings(%q)[%q] = %v; want %v", test.header, coding, got[coding], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// FieldName is the form field forms carry the token in.
+const FieldName = "csrf_token"
+
+// HeaderName is checked for the token when the form field is absent, for
+// requests made from scripts.
+const HeaderName = "X-CSRF-Token"
+
+const (
+	sessionKey = "csrf_token"
+	tokenSize  = 32
+)
+
+type contextKey struct{}
+
+// Protect keeps a secret token in each visitor's session and rejects
+// unsafe requests that do not echo it back.
+type Protect struct {
+	Store       sessions.Store
+	SessionName string
+}
+
+// New returns a Protect using the named session from store.
+func New(store sessions.Store, sessionName string) *Protect {
+	return &Protect{Store: store, SessionName: sessionName}
+}
+
+// Token returns the token to render into the request's forms. Each call
+// returns a differently masked copy of the session's token, so it never
+// appears twice in a compressed response. It is empty when the middleware
+// is not in use.
+func Token(r *http.Request) string {
+	secret, ok := r.Context().Value(contextKey{}).([]byte)
+	if !ok {
+		return ""
+	}
+
+	return mask(secret)
+}
+
+// Handler makes sure the session holds a token and checks it on every
+// request that is not GET, HEAD, OPTIONS or TRACE.
+func (p *Protect) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := p.Store.Get(r, p.SessionName)
+		if session == nil {
+			log.Printf("Failed to get session for CSRF check: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			// An unreadable cookie gets a fresh session.
+			log.Printf("Failed to read session for CSRF check: %v", err)
+		}
+
+		secret, err := secretFrom(session)
+		if err != nil {
+			log.Printf("Failed to create CSRF token: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		default:
+			sent := r.PostFormValue(FieldName)
+			if sent == "" {
+				sent = r.Header.Get(HeaderName)
+			}
+
+			if !valid(secret, sent) {
+				log.Printf("Rejected %s %s: invalid CSRF token", r.Method, r.URL.Path)
+				http.Error(w, "Forbidden - invalid CSRF token", http.StatusForbidden)
+				return
+			}
+		}
+
+		if session.Values[sessionKey] == nil {
+			session.Values[sessionKey] = base64.StdEncoding.EncodeToString(secret)
+			if err := session.Save(r, w); err != nil {
+				log.Printf("Failed to save CSRF token: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, secret)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Renew gives session a new token in place of any it had, so one a
+// visitor was handed before logging in stops working after. The caller
+// saves the session.
+func Renew(session *sessions.Session) error {
+	delete(session.Values, sessionKey)
+
+	secret, err := secretFrom(session)
+	if err != nil {
+		return err
+	}
+
+	session.Values[sessionKey] = base64.StdEncoding.EncodeToString(secret)
+	return nil
+}
+
+// secretFrom returns the session's token, creating one if it has none.
+func secretFrom(session *sessions.Session) ([]byte, error) {
+	if encoded, ok := session.Values[sessionKey].(string); ok {
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err == nil && len(secret) == tokenSize {
+			return secret, nil
+		}
+	}
+
+	secret := make([]byte, tokenSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	delete(session.Values, sessionKey)
+	return secret, nil
+}
+
+// mask XORs the secret with a one-time pad and returns the pad followed by
+// the result.
+func mask(secret []byte) string {
+	pad := make([]byte, len(secret))
+	if _, err := rand.Read(pad); err != nil {
+		return ""
+	}
+
+	masked := make([]byte, 2*len(secret))
+	copy(masked, pad)
+	for i := range secret {
+		masked[len(secret)+i] = pad[i] ^ secret[i]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// valid reports whether a masked token sent by the client matches secret.
+func valid(secret []byte, sent string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*len(secret) {
+		return false
+	}
+
+	unmasked := make([]byte, len(secret))
+	for i := range secret {
+		unmasked[i] = masked[i] ^ masked[len(secret)+i]
+	}
+
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
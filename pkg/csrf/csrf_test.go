@@ -0,0 +1,135 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+// visit runs a request through the middleware, carrying over cookies, and
+// returns the response with the token the handler saw.
+func visit(t *testing.T, p *Protect, req *http.Request, cookies []*http.Cookie) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	var token string
+	handler := p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = Token(r)
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec, token
+}
+
+func post(form url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestProtect(t *testing.T) {
+	p := New(sessions.NewCookieStore([]byte("test-secret-key")), "test")
+
+	rec, token := visit(t, p, httptest.NewRequest("GET", "/form", nil), nil)
+	if rec.Code != http.StatusOK || token == "" {
+		t.Fatalf("GET status = %d, token = %q; want 200 and a token", rec.Code, token)
+	}
+	cookies := rec.Result().Cookies()
+
+	_, other := visit(t, p, httptest.NewRequest("GET", "/form", nil), cookies)
+	if other == token {
+		t.Errorf("two renders returned the same masked token")
+	}
+
+	_, stranger := visit(t, p, httptest.NewRequest("GET", "/form", nil), nil)
+
+	header := post(url.Values{})
+	header.Header.Set(HeaderName, token)
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		cookies []*http.Cookie
+		status  int
+	}{
+		{"valid token", post(url.Values{FieldName: {token}}), cookies, http.StatusOK},
+		{"second masked copy", post(url.Values{FieldName: {other}}), cookies, http.StatusOK},
+		{"header token", header, cookies, http.StatusOK},
+		{"missing token", post(url.Values{}), cookies, http.StatusForbidden},
+		{"garbage token", post(url.Values{FieldName: {"not-a-token"}}), cookies, http.StatusForbidden},
+		{"another session's token", post(url.Values{FieldName: {stranger}}), cookies, http.StatusForbidden},
+		{"no session", post(url.Values{FieldName: {token}}), nil, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec, _ := visit(t, p, test.req, test.cookies)
+			if rec.Code != test.status {
+				t.Errorf("status = %d; want %d", rec.Code, test.status)
+			}
+		})
+	}
+}
+
+func TestRenew(t *testing.T) {
+	store := sessions.NewCookieStore([]byte("test-secret-key"))
+	p := New(store, "test")
+
+	rec, token := visit(t, p, httptest.NewRequest("GET", "/form", nil), nil)
+	req := httptest.NewRequest("GET", "/login", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	session, _ := store.Get(req, "test")
+	if err := Renew(session); err != nil {
+		t.Fatalf("Renew returned an error: %v", err)
+	}
+	renewed := httptest.NewRecorder()
+	if err := session.Save(req, renewed); err != nil {
+		t.Fatalf("Save returned an error: %v", err)
+	}
+	cookies := renewed.Result().Cookies()
+
+	rec, _ = visit(t, p, post(url.Values{FieldName: {token}}), cookies)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("old token status = %d; want %d", rec.Code, http.StatusForbidden)
+	}
+
+	_, fresh := visit(t, p, httptest.NewRequest("GET", "/form", nil), cookies)
+	rec, _ = visit(t, p, post(url.Values{FieldName: {fresh}}), cookies)
+	if rec.Code != http.StatusOK {
+		t.Errorf("new token status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestTokenWithoutMiddleware(t *testing.T) {
+	if got := Token(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("Token = %q; want an empty string", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	secret := []byte(strings.Repeat("s", tokenSize))
+	other := []byte(strings.Repeat("o", tokenSize))
+
+	token := mask(secret)
+	if !valid(secret, token) {
+		t.Errorf("valid(secret, mask(secret)) = false")
+	}
+	if valid(other, token) {
+		t.Errorf("valid(other, mask(secret)) = true")
+	}
+	if valid(secret, token[:len(token)-2]) {
+		t.Errorf("valid accepted a truncated token")
+	}
+}
@@ -0,0 +1,209 @@
+package fileserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/compress"
+)
+
+// types covers extensions the standard library may not know, depending on
+// the mime.types files installed on the host.
+var types = map[string]string{
+	".eot":         "application/vnd.ms-fontobject",
+	".ico":         "image/x-icon",
+	".map":         "application/json",
+	".md":          "text/markdown; charset=utf-8",
+	".otf":         "font/otf",
+	".ttf":         "font/ttf",
+	".txt":         "text/plain; charset=utf-8",
+	".webmanifest": "application/manifest+json",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+}
+
+// encodings lists the precompressed variants in order of preference. A
+// variant is a file next to the original with the suffix appended, e.g.
+// main.min.css.br.
+var encodings = []struct {
+	name   string
+	suffix string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Server serves files from an fs.FS. Files with a precompressed .br or .gz
+// copy are sent compressed to clients that accept it, and range and
+// conditional requests are handled by http.ServeContent.
+type Server struct {
+	FS           fs.FS
+	CacheControl string
+
+	etags sync.Map
+}
+
+// New returns a Server for fsys. cacheControl is sent with every file unless
+// the response already carries a Cache-Control header.
+func New(fsys fs.FS, cacheControl string) *Server {
+	return &Server{FS: fsys, CacheControl: cacheControl}
+}
+
+// Handler serves GET and HEAD requests for files that exist and passes
+// every other request to next, like nginx's try_files.
+func (s *Server) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.ServeFile(w, r, r.URL.Path) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// ServeFile writes the file at the URL path name and reports whether it was
+// found. Nothing is written when it returns false.
+func (s *Server) ServeFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	file := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if file == "" {
+		return false
+	}
+
+	info, err := fs.Stat(s.FS, file)
+	if err != nil {
+		return false
+	}
+
+	if info.IsDir() {
+		index := path.Join(file, "index.html")
+		if _, err := fs.Stat(s.FS, index); err != nil {
+			return false
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return true
+		}
+		file = index
+	}
+
+	contentType := types[path.Ext(file)]
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(file))
+	}
+
+	send, encoding := s.variant(r, file)
+
+	f, err := s.FS.Open(send)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	content, info, err := seekable(f)
+	if err != nil {
+		return false
+	}
+
+	etag, err := s.etag(send, info)
+	if err != nil {
+		return false
+	}
+
+	header := w.Header()
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	if s.hasVariant(file) {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if header.Get("Cache-Control") == "" && s.CacheControl != "" {
+		header.Set("Cache-Control", s.CacheControl)
+	}
+	header.Set("ETag", etag)
+
+	http.ServeContent(w, r, file, info.ModTime(), content)
+	return true
+}
+
+// variant picks the precompressed copy of file the client accepts, if any.
+func (s *Server) variant(r *http.Request, file string) (string, string) {
+	accepted := compress.AcceptEncodings(r.Header.Get("Accept-Encoding"))
+
+	for _, e := range encodings {
+		if !accepted[e.name] {
+			continue
+		}
+		if _, err := fs.Stat(s.FS, file+e.suffix); err == nil {
+			return file + e.suffix, e.name
+		}
+	}
+
+	return file, ""
+}
+
+func (s *Server) hasVariant(file string) bool {
+	for _, e := range encodings {
+		if _, err := fs.Stat(s.FS, file+e.suffix); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// seekable returns f as an io.ReadSeeker for http.ServeContent. Files that
+// cannot seek are read into memory.
+func seekable(f fs.File) (io.ReadSeeker, fs.FileInfo, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, info, nil
+	}
+
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return bytes.NewReader(contents), info, nil
+}
+
+// etag returns a strong validator for file. It is computed once per file
+// version, so files edited on disk get a new one.
+func (s *Server) etag(file string, info fs.FileInfo) (string, error) {
+	key := fmt.Sprintf("%s:%d:%d", file, info.ModTime().UnixNano(), info.Size())
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	f, err := s.FS.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil))[:16] + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
@@ -0,0 +1,147 @@
+package fileserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"css/main.css":         {Data: []byte("body{color:red}")},
+		"css/main.css.br":      {Data: []byte("brotli")},
+		"css/main.css.gz":      {Data: []byte("gzip")},
+		"js/main.js":           {Data: []byte("var a = 1;")},
+		"fonts/font.woff2":     {Data: []byte("font")},
+		"apps/game/index.html": {Data: []byte("<p>game</p>")},
+	}
+}
+
+func TestServeFile(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := New(testFS(), "public, max-age=60").Handler(next)
+
+	tests := []struct {
+		name            string
+		method          string
+		url             string
+		acceptEncoding  string
+		status          int
+		contentType     string
+		contentEncoding string
+		body            string
+	}{
+		{"plain", "GET", "/js/main.js", "", http.StatusOK, "text/javascript; charset=utf-8", "", "var a = 1;"},
+		{"brotli preferred", "GET", "/css/main.css", "gzip, br", http.StatusOK, "text/css; charset=utf-8", "br", "brotli"},
+		{"gzip", "GET", "/css/main.css", "gzip", http.StatusOK, "text/css; charset=utf-8", "gzip", "gzip"},
+		{"brotli refused", "GET", "/css/main.css", "br;q=0, gzip", http.StatusOK, "text/css; charset=utf-8", "gzip", "gzip"},
+		{"no variant accepted", "GET", "/css/main.css", "deflate", http.StatusOK, "text/css; charset=utf-8", "", "body{color:red}"},
+		{"font type", "GET", "/fonts/font.woff2", "", http.StatusOK, "font/woff2", "", "font"},
+		{"directory index", "GET", "/apps/game/", "", http.StatusOK, "text/html; charset=utf-8", "", "<p>game</p>"},
+		{"directory redirect", "GET", "/apps/game", "", http.StatusMovedPermanently, "", "", ""},
+		{"directory without index", "GET", "/css/", "", http.StatusTeapot, "", "", ""},
+		{"missing", "GET", "/js/missing.js", "", http.StatusTeapot, "", "", ""},
+		{"root", "GET", "/", "", http.StatusTeapot, "", "", ""},
+		{"post", "POST", "/js/main.js", "", http.StatusTeapot, "", "", ""},
+		{"escape", "GET", "/../js/main.js", "", http.StatusOK, "text/javascript; charset=utf-8", "", "var a = 1;"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.url, nil)
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatalf("%s %s status = %d; want %d", test.method, test.url, rec.Code, test.status)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != test.contentType {
+				t.Errorf("Content-Type = %q; want %q", got, test.contentType)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != test.contentEncoding {
+				t.Errorf("Content-Encoding = %q; want %q", got, test.contentEncoding)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "public, max-age=60" {
+				t.Errorf("Cache-Control = %q; want %q", got, "public, max-age=60")
+			}
+			if got := rec.Body.String(); got != test.body {
+				t.Errorf("body = %q; want %q", got, test.body)
+			}
+		})
+	}
+}
+
+func TestServeFileVary(t *testing.T) {
+	s := New(testFS(), "")
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/css/main.css", "Accept-Encoding"},
+		{"/js/main.js", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeFile(rec, httptest.NewRequest("GET", test.url, nil), test.url)
+
+			if got := rec.Header().Get("Vary"); got != test.want {
+				t.Errorf("Vary = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestServeFileRange(t *testing.T) {
+	s := New(testFS(), "")
+
+	req := httptest.NewRequest("GET", "/js/main.js", nil)
+	req.Header.Set("Range", "bytes=4-4")
+
+	rec := httptest.NewRecorder()
+	s.ServeFile(rec, req, req.URL.Path)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusPartialContent)
+	}
+	if got := rec.Body.String(); got != "a" {
+		t.Errorf("body = %q; want %q", got, "a")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 4-4/10" {
+		t.Errorf("Content-Range = %q; want %q", got, "bytes 4-4/10")
+	}
+}
+
+func TestServeFileConditional(t *testing.T) {
+	s := New(testFS(), "")
+
+	rec := httptest.NewRecorder()
+	s.ServeFile(rec, httptest.NewRequest("GET", "/js/main.js", nil), "/js/main.js")
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("no ETag was set")
+	}
+
+	req := httptest.NewRequest("GET", "/js/main.js", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	rec = httptest.NewRecorder()
+	s.ServeFile(rec, req, req.URL.Path)
+
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusNotModified)
+	}
+}
@@ -0,0 +1,176 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+// Policy adds conditional request handling and a Cache-Control header to a
+// group of routes. Successful GET and HEAD responses are buffered so an ETag
+// can be computed from the rendered output; requests whose If-None-Match or
+// If-Modified-Since matches get a 304 without a body.
+type Policy struct {
+	CacheControl string
+}
+
+// New returns a Policy using cacheControl, or fallback when it is empty.
+// It lets a route group's policy be configured from the environment.
+func New(cacheControl, fallback string) *Policy {
+	if cacheControl == "" {
+		cacheControl = fallback
+	}
+	return &Policy{CacheControl: cacheControl}
+}
+
+// SetLastModified sets the Last-Modified header, truncated to the second
+// precision the header carries.
+func SetLastModified(w http.ResponseWriter, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// Handler wraps next with the policy.
+func (p *Policy) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		h := w.Header()
+		if buf.status != http.StatusOK {
+			w.WriteHeader(buf.status)
+			buf.body.WriteTo(w)
+			return
+		}
+
+		body := buf.body.Bytes()
+		nonce := []byte(secure.Nonce(r))
+		nonced := len(nonce) > 0 && bytes.Contains(body, nonce)
+
+		if h.Get("Cache-Control") == "" {
+			cacheControl := p.CacheControl
+			if nonced {
+				cacheControl = private(cacheControl)
+			}
+			h.Set("Cache-Control", cacheControl)
+		}
+		if h.Get("ETag") == "" {
+			// Pages carry a per-request CSP nonce. It is left out so the
+			// ETag only changes with the content.
+			if nonced {
+				body = bytes.ReplaceAll(body, nonce, nil)
+			}
+
+			sum := sha256.Sum256(body)
+			h.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		}
+
+		if notModified(r, h) {
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			buf.body.WriteTo(w)
+		}
+	})
+}
+
+// HandlerFunc wraps a handler function with the policy.
+func (p *Policy) HandlerFunc(next http.HandlerFunc) http.Handler {
+	return p.Handler(next)
+}
+
+// private turns a public Cache-Control into a private one. A page with a
+// nonce in it only works with the policy it was sent with, so a shared
+// cache handing it to other visitors would break their scripts, and would
+// let anyone who saw the nonce use it.
+func private(cacheControl string) string {
+	directives := strings.Split(cacheControl, ",")
+	for i, directive := range directives {
+		if strings.EqualFold(strings.TrimSpace(directive), "public") {
+			directives[i] = strings.Replace(directive, strings.TrimSpace(directive), "private", 1)
+		}
+	}
+	return strings.Join(directives, ",")
+}
+
+// notModified evaluates the request's conditional headers against the
+// response's validators. If-Modified-Since is ignored when If-None-Match is
+// present.
+func notModified(r *http.Request, h http.Header) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, h.Get("ETag"))
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	lm := h.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+
+	return !modified.After(since)
+}
+
+// etagMatches reports whether etag is listed in an If-None-Match header,
+// using the weak comparison the header calls for.
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds the status and body until the handler returns.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.body.Write(b)
+}
@@ -0,0 +1,145 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+)
+
+var modified = time.Date(2024, 9, 28, 12, 0, 0, 0, time.UTC)
+
+func page(w http.ResponseWriter, r *http.Request) {
+	SetLastModified(w, modified)
+	w.Write([]byte("hello"))
+}
+
+func TestPolicy(t *testing.T) {
+	p := New("", "public, max-age=60")
+	handler := p.HandlerFunc(page)
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest("GET", "/", nil))
+	etag := first.Header().Get("ETag")
+
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		status   int
+		wantBody string
+	}{
+		{"plain", "GET", nil, http.StatusOK, "hello"},
+		{"head", "HEAD", nil, http.StatusOK, ""},
+		{"etag match", "GET", map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{"weak etag match", "GET", map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified, ""},
+		{"wildcard", "GET", map[string]string{"If-None-Match": "*"}, http.StatusNotModified, ""},
+		{"etag mismatch", "GET", map[string]string{"If-None-Match": `"other"`}, http.StatusOK, "hello"},
+		{"etag mismatch wins over date", "GET", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusOK, "hello"},
+		{"not modified since", "GET", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified, ""},
+		{"modified since", "GET", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK, "hello"},
+		{"bad date", "GET", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK, "hello"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/", nil)
+			for k, v := range test.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("status = %d; want %d", w.Code, test.status)
+			}
+			if w.Body.String() != test.wantBody {
+				t.Errorf("body = %q; want %q", w.Body.String(), test.wantBody)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q; want %q", w.Header().Get("ETag"), etag)
+			}
+			if w.Header().Get("Cache-Control") != "public, max-age=60" {
+				t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+			}
+			if w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q", w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
+
+func TestPolicySkipsErrorsAndWrites(t *testing.T) {
+	p := New("private", "public")
+
+	failing := p.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	w := httptest.NewRecorder()
+	failing.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusInternalServerError || w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("error response was cached: %d %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	p.HandlerFunc(page).ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("POST response was cached: %v", w.Header())
+	}
+}
+
+func TestPolicyIgnoresNonce(t *testing.T) {
+	p := New("", "public, max-age=60")
+	headers := secure.New(secure.Config{Policy: secure.DefaultPolicy})
+
+	handler := headers.Handler(p.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<script nonce="` + secure.Nonce(r) + `"></script>`))
+	}))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest("GET", "/", nil))
+	etag := first.Header().Get("ETag")
+	if first.Header().Get("Content-Security-Policy") == "" {
+		t.Fatalf("no Content-Security-Policy on the first response")
+	}
+	if got := first.Header().Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("Cache-Control = %q on a page with a nonce; want %q", got, "private, max-age=60")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", etag)
+
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, r)
+
+	if second.Code != http.StatusNotModified {
+		t.Fatalf("status = %d; want %d", second.Code, http.StatusNotModified)
+	}
+	// The browser keeps the cached page, so it must keep the policy that
+	// matches the nonce in it.
+	if got := second.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Content-Security-Policy = %q on a 304; want none", got)
+	}
+}
+
+func TestPrivate(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		want         string
+	}{
+		{"public, max-age=300", "private, max-age=300"},
+		{"max-age=300, Public", "max-age=300, private"},
+		{"no-cache", "no-cache"},
+		{"private, max-age=60", "private, max-age=60"},
+	}
+
+	for _, test := range tests {
+		if got := private(test.cacheControl); got != test.want {
+			t.Errorf("private(%q) = %q; want %q", test.cacheControl, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"log"
+	"os"
+
+	"github.com/mailgun/mailgun-go"
+)
+
+// Sender delivers plain text email.
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// Mailgun sends through the Mailgun API.
+type Mailgun struct {
+	Domain string
+	APIKey string
+	From   string
+}
+
+func (m *Mailgun) Send(to, subject, body string) error {
+	mg := mailgun.NewMailgun(m.Domain, m.APIKey)
+	_, _, err := mg.Send(mg.NewMessage(m.From, subject, body, to))
+	return err
+}
+
+// Log writes messages to the log instead of sending them, for development.
+type Log struct{}
+
+func (Log) Send(to, subject, body string) error {
+	log.Printf("Mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// FromEnv returns a Mailgun sender when MAILGUN_DOMAIN and MAILGUN_API_KEY
+// are set, and a Log sender otherwise. Mail comes from MAIL_FROM, or
+// SUPPORT_EMAIL when that is unset.
+func FromEnv() Sender {
+	domain := os.Getenv("MAILGUN_DOMAIN")
+	apiKey := os.Getenv("MAILGUN_API_KEY")
+	if domain == "" || apiKey == "" {
+		log.Println("MAILGUN_DOMAIN or MAILGUN_API_KEY is unset; mail will be logged, not sent")
+		return Log{}
+	}
+
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SUPPORT_EMAIL")
+	}
+
+	return &Mailgun{Domain: domain, APIKey: apiKey, From: from}
+}
@@ -0,0 +1,171 @@
+// Package passkeys signs users in with WebAuthn credentials. It wraps
+// go-webauthn so handlers only deal with the site's address, an account's
+// stored credentials and an opaque state string kept in the session between
+// the two halves of each ceremony.
+package passkeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// ErrCloned is returned by FinishLogin when the authenticator's signature
+// counter went backwards, which means the credential has been copied.
+var ErrCloned = errors.New("passkeys: signature counter went backwards")
+
+// New returns the relying party for the site at appURL, shown as name in
+// the browser's prompts. Passkeys only work on that host.
+func New(name, appURL string) (*webauthn.WebAuthn, error) {
+	u, err := url.Parse(appURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, fmt.Errorf("passkeys: %q is not an absolute URL", appURL)
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: name,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+	})
+}
+
+// User is an account and the credentials it has registered. Its user
+// handle is the account ID, which is how a passkey offered without an
+// email is traced back to its owner.
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Credentials []webauthn.Credential
+}
+
+func (u *User) WebAuthnID() []byte                         { return []byte(u.ID) }
+func (u *User) WebAuthnName() string                       { return u.Name }
+func (u *User) WebAuthnDisplayName() string                { return u.DisplayName }
+func (u *User) WebAuthnCredentials() []webauthn.Credential { return u.Credentials }
+func (u *User) WebAuthnIcon() string                       { return "" }
+
+// BeginRegistration returns the options for navigator.credentials.create
+// and the state FinishRegistration needs. Credentials the user already has
+// are excluded so one authenticator is not registered twice.
+func BeginRegistration(rp *webauthn.WebAuthn, user *User) (*protocol.CredentialCreation, string, error) {
+	exclude := make([]protocol.CredentialDescriptor, len(user.Credentials))
+	for i, credential := range user.Credentials {
+		exclude[i] = credential.Descriptor()
+	}
+
+	creation, session, err := rp.BeginRegistration(user,
+		webauthn.WithExclusions(exclude),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	state, err := encode(session)
+	return creation, state, err
+}
+
+// FinishRegistration checks the authenticator's response in body and
+// returns the new credential to store.
+func FinishRegistration(rp *webauthn.WebAuthn, user *User, state string, body io.Reader) (*webauthn.Credential, error) {
+	session, err := decode(state)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := protocol.ParseCredentialCreationResponseBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return rp.CreateCredential(user, session, response)
+}
+
+// BeginLogin returns the options for navigator.credentials.get and the
+// state FinishLogin needs. With a user it asks for one of their passkeys as
+// a second factor. Without one any passkey for the site may answer, and
+// the authenticator must verify the user itself, since the passkey is then
+// the only factor.
+func BeginLogin(rp *webauthn.WebAuthn, user *User) (*protocol.CredentialAssertion, string, error) {
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	var err error
+
+	if user == nil {
+		assertion, session, err = rp.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	} else {
+		assertion, session, err = rp.BeginLogin(user)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	state, err := encode(session)
+	return assertion, state, err
+}
+
+// FinishLogin checks the authenticator's response in body. lookup loads an
+// account and its credentials by ID. It returns the user who signed in and
+// the credential they used, with its signature counter updated. When the
+// response names a known account but fails, that user is still returned
+// with the error so the failure can be counted against them.
+func FinishLogin(rp *webauthn.WebAuthn, state string, body io.Reader, lookup func(id string) (*User, error)) (*User, *webauthn.Credential, error) {
+	session, err := decode(state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := protocol.ParseCredentialRequestResponseBody(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user *User
+	var credential *webauthn.Credential
+
+	if session.UserID == nil {
+		credential, err = rp.ValidateDiscoverableLogin(func(_, userHandle []byte) (webauthn.User, error) {
+			found, err := lookup(string(userHandle))
+			user = found
+			return found, err
+		}, session, response)
+	} else {
+		user, err = lookup(string(session.UserID))
+		if err != nil {
+			return nil, nil, err
+		}
+		credential, err = rp.ValidateLogin(user, session, response)
+	}
+	if err != nil {
+		return user, nil, err
+	}
+
+	if credential.Authenticator.CloneWarning {
+		return user, credential, ErrCloned
+	}
+
+	return user, credential, nil
+}
+
+func encode(session *webauthn.SessionData) (string, error) {
+	b, err := json.Marshal(session)
+	return string(b), err
+}
+
+func decode(state string) (webauthn.SessionData, error) {
+	var session webauthn.SessionData
+	if state == "" {
+		return session, errors.New("passkeys: no ceremony in progress")
+	}
+	err := json.Unmarshal([]byte(state), &session)
+	return session, err
+}
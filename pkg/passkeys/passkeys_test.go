@@ -0,0 +1,309 @@
+package passkeys
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// authenticator is a software passkey: one P-256 key answering the
+// ceremonies the way a browser and security key would.
+type authenticator struct {
+	rpID       string
+	origin     string
+	id         []byte
+	key        *ecdsa.PrivateKey
+	counter    uint32
+	userHandle []byte
+	flags      byte
+}
+
+func newAuthenticator(t *testing.T, rpID, origin string) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	return &authenticator{rpID: rpID, origin: origin, id: id, key: key, flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	var b bytes.Buffer
+	b.Write(rpIDHash[:])
+	b.WriteByte(flags)
+	binary.Write(&b, binary.BigEndian, a.counter)
+	return b.Bytes()
+}
+
+func (a *authenticator) clientData(t *testing.T, kind string, challenge protocol.URLEncodedBase64) []byte {
+	b, err := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// create answers navigator.credentials.create with "none" attestation.
+func (a *authenticator) create(t *testing.T, creation *protocol.CredentialCreation) []byte {
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1,
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(a.flags | flagAttested)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.id)))
+	authData = append(authData, a.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.respond(t, map[string]string{
+		"clientDataJSON":    encode64(a.clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode64(attestation),
+	})
+}
+
+// get answers navigator.credentials.get, counting one more signature.
+func (a *authenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) []byte {
+	a.counter++
+
+	clientData := a.clientData(t, "webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	authData := a.authData(a.flags)
+
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.respond(t, map[string]string{
+		"clientDataJSON":    encode64(clientData),
+		"authenticatorData": encode64(authData),
+		"signature":         encode64(signature),
+		"userHandle":        encode64(a.userHandle),
+	})
+}
+
+func (a *authenticator) respond(t *testing.T, response map[string]string) []byte {
+	b, err := json.Marshal(map[string]interface{}{
+		"id":       encode64(a.id),
+		"rawId":    encode64(a.id),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func encode64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newRP(t *testing.T) *webauthn.WebAuthn {
+	rp, err := New("Test", "https://example.com/admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+// register runs a registration ceremony for user and stores the result on
+// it.
+func register(t *testing.T, rp *webauthn.WebAuthn, user *User, a *authenticator) {
+	creation, state, err := BeginRegistration(rp, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := FinishRegistration(rp, user, state, bytes.NewReader(a.create(t, creation)))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if !bytes.Equal(credential.ID, a.id) {
+		t.Fatalf("credential ID = %x, want %x", credential.ID, a.id)
+	}
+
+	user.Credentials = append(user.Credentials, *credential)
+}
+
+func TestNew(t *testing.T) {
+	rp := newRP(t)
+	if rp.Config.RPID != "example.com" {
+		t.Errorf("RPID = %q, want example.com", rp.Config.RPID)
+	}
+	if len(rp.Config.RPOrigins) != 1 || rp.Config.RPOrigins[0] != "https://example.com" {
+		t.Errorf("RPOrigins = %v, want [https://example.com]", rp.Config.RPOrigins)
+	}
+
+	_, err := New("Test", "/admin")
+	if err == nil {
+		t.Error("New accepted a relative URL")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	rp := newRP(t)
+
+	tests := []struct {
+		name    string
+		user    bool
+		origin  string
+		flags   byte
+		counter uint32
+		wantErr bool
+	}{
+		{name: "second factor", user: true, origin: "https://example.com", flags: flagUserPresent},
+		{name: "passwordless", origin: "https://example.com", flags: flagUserPresent | flagUserVerified},
+		{name: "passwordless without verification", origin: "https://example.com", flags: flagUserPresent, wantErr: true},
+		{name: "wrong origin", user: true, origin: "https://example.org", flags: flagUserPresent, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{ID: "7f0c6e1a-4c5b-4a47-9b7e-2f7f8c0d1e2a", Name: "jane@example.com", DisplayName: "Jane Doe"}
+			a := newAuthenticator(t, "example.com", "https://example.com")
+			register(t, rp, user, a)
+
+			var begin *User
+			if tt.user {
+				begin = user
+			}
+
+			assertion, state, err := BeginLogin(rp, begin)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a.origin = tt.origin
+			a.flags = tt.flags
+			lookup := func(id string) (*User, error) {
+				if id != user.ID {
+					return nil, fmt.Errorf("no user %q", id)
+				}
+				return user, nil
+			}
+
+			got, credential, err := FinishLogin(rp, state, bytes.NewReader(a.get(t, assertion)), lookup)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("FinishLogin succeeded, want an error")
+				}
+				// The failure is still pinned on the account it named
+				if got == nil || got.ID != user.ID {
+					t.Errorf("user = %v, want %q", got, user.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FinishLogin: %v", err)
+			}
+			if got.ID != user.ID {
+				t.Errorf("user = %q, want %q", got.ID, user.ID)
+			}
+			if credential.Authenticator.SignCount != a.counter {
+				t.Errorf("SignCount = %d, want %d", credential.Authenticator.SignCount, a.counter)
+			}
+		})
+	}
+}
+
+func TestLoginCloned(t *testing.T) {
+	rp := newRP(t)
+	user := &User{ID: "7f0c6e1a-4c5b-4a47-9b7e-2f7f8c0d1e2a", Name: "jane@example.com", DisplayName: "Jane Doe"}
+	a := newAuthenticator(t, "example.com", "https://example.com")
+	register(t, rp, user, a)
+	user.Credentials[0].Authenticator.SignCount = 10
+
+	assertion, state, err := BeginLogin(rp, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = FinishLogin(rp, state, bytes.NewReader(a.get(t, assertion)), func(string) (*User, error) {
+		return user, nil
+	})
+	if !errors.Is(err, ErrCloned) {
+		t.Errorf("err = %v, want ErrCloned", err)
+	}
+}
+
+func TestFinishLoginReplay(t *testing.T) {
+	rp := newRP(t)
+	user := &User{ID: "7f0c6e1a-4c5b-4a47-9b7e-2f7f8c0d1e2a", Name: "jane@example.com", DisplayName: "Jane Doe"}
+	a := newAuthenticator(t, "example.com", "https://example.com")
+	register(t, rp, user, a)
+
+	first, _, err := BeginLogin(rp, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := a.get(t, first)
+
+	_, state, err := BeginLogin(rp, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = FinishLogin(rp, state, bytes.NewReader(response), func(string) (*User, error) {
+		return user, nil
+	})
+	if err == nil {
+		t.Error("FinishLogin accepted a response to another challenge")
+	}
+
+	_, _, err = FinishLogin(rp, "", bytes.NewReader(response), func(string) (*User, error) {
+		return user, nil
+	})
+	if err == nil {
+		t.Error("FinishLogin accepted a response without a ceremony")
+	}
+}
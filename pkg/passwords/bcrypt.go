@@ -0,0 +1,24 @@
+package passwords
+
+import "golang.org/x/crypto/bcrypt"
+
+// Bcrypt hashes with bcrypt, whose own $2a$ format serves as its PHC
+// string. Passwords longer than 72 bytes are refused.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	return string(bytes), err
+}
+
+func (b Bcrypt) Current(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost == b.Cost
+}
+
+func verifyBcrypt(password, hash string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
@@ -0,0 +1,64 @@
+package passwords
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// Breached looks passwords up in a local copy of a breached password list
+// split by hash prefix, the k-anonymity layout Have I Been Pwned serves its
+// range API in. Dir holds one file per five character SHA-1 prefix, named
+// like 5BAA6 or 5BAA6.txt, whose lines are the rest of a hash and a count:
+//
+//	1E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004
+//
+// Only the file for one prefix is read per lookup, and a missing file
+// means no listed password has that prefix. Padding lines with a count of
+// 0 are ignored.
+type Breached struct {
+	Dir fs.FS
+}
+
+// NewBreached returns a Breached reading the range files in dir.
+func NewBreached(dir string) *Breached {
+	return &Breached{Dir: os.DirFS(dir)}
+}
+
+// Contains reports whether password is on the list.
+func (b *Breached) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		file, err := b.Dir.Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line, count, _ := strings.Cut(scanner.Text(), ":")
+
+			// Padding lines, added to hide the size of a range, count 0
+			if strings.TrimSpace(count) == "0" {
+				continue
+			}
+			if strings.EqualFold(strings.TrimSpace(line), suffix) {
+				return true, nil
+			}
+		}
+		return false, scanner.Err()
+	}
+
+	return false, nil
+}
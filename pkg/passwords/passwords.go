@@ -1,17 +1,85 @@
+// Package passwords hashes and checks passwords. Hashes are stored as PHC
+// strings, which name the algorithm and parameters they were made with, so
+// a hash made by any supported algorithm can still be checked after the
+// configured Hasher changes, and NeedsRehash can tell which are out of date.
 package passwords
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
 )
 
-var cost int = 14
+// Hasher makes password hashes with one algorithm and set of parameters.
+type Hasher interface {
+	// Hash returns a PHC string for password with a fresh salt.
+	Hash(password string) (string, error)
+
+	// Current reports whether hash was made by this algorithm with these
+	// parameters.
+	Current(hash string) bool
+}
+
+var (
+	mu      sync.Mutex
+	current Hasher = DefaultArgon2id
+	dummy   string
+)
+
+// Use makes h the Hasher for new passwords. Call it once at startup.
+func Use(h Hasher) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	current = h
+	dummy = ""
+}
+
+func hasher() Hasher {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return current
+}
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	return string(bytes), err
+	return hasher().Hash(password)
 }
 
+// CheckPasswordHash reports whether password matches hash, whichever
+// supported algorithm made it.
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2id(password, hash)
+	case strings.HasPrefix(hash, "$2"):
+		return verifyBcrypt(password, hash)
+	}
+	return false
+}
+
+// NeedsRehash reports whether hash should be replaced with one from the
+// configured Hasher, the next time the password is known.
+func NeedsRehash(hash string) bool {
+	return !hasher().Current(hash)
+}
+
+// DummyCompare does the work of CheckPasswordHash against a hash nothing
+// matches, so a login for an unknown account takes as long as one with a
+// wrong password.
+func DummyCompare(password string) {
+	mu.Lock()
+	h, hash := current, dummy
+	mu.Unlock()
+
+	if hash == "" {
+		hash, _ = h.Hash("dummy password")
+
+		mu.Lock()
+		if current == h {
+			dummy = hash
+		}
+		mu.Unlock()
+	}
+
+	CheckPasswordHash(password, hash)
 }
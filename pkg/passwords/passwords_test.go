@@ -1,9 +1,13 @@
 package passwords
 
 import (
+	"strings"
 	"testing"
 )
 
+// fast keeps the tests quick; the defaults are tuned for logins, not loops.
+var fast = Argon2id{Memory: 1024, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
 func TestHashPassword(t *testing.T) {
 	password := "mysecretpassword"
 	hash, err := HashPassword(password)
@@ -11,8 +15,8 @@ func TestHashPassword(t *testing.T) {
 		t.Fatalf("HashPassword returned an error: %v", err)
 	}
 
-	if len(hash) == 0 {
-		t.Fatalf("HashPassword returned an empty hash")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Fatalf("HashPassword = %q; want an argon2id hash with the default parameters", hash)
 	}
 }
 
@@ -31,3 +35,118 @@ func TestCheckPasswordHash(t *testing.T) {
 		t.Fatalf("CheckPasswordHash returned true for an invalid password and hash")
 	}
 }
+
+func TestHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{"argon2id", fast, "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"bcrypt", Bcrypt{Cost: 4}, "$2a$04$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := test.hasher.Hash("correct horse battery")
+			if err != nil {
+				t.Fatalf("Hash returned an error: %v", err)
+			}
+			if !strings.HasPrefix(hash, test.prefix) {
+				t.Errorf("Hash = %q; want prefix %q", hash, test.prefix)
+			}
+
+			other, _ := test.hasher.Hash("correct horse battery")
+			if other == hash {
+				t.Error("two hashes of the same password are equal; want a fresh salt each time")
+			}
+
+			if !CheckPasswordHash("correct horse battery", hash) {
+				t.Error("CheckPasswordHash returned false for the right password")
+			}
+			if CheckPasswordHash("correct horse staple", hash) {
+				t.Error("CheckPasswordHash returned true for the wrong password")
+			}
+			if !test.hasher.Current(hash) {
+				t.Error("Current returned false for the hasher's own hash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer Use(DefaultArgon2id)
+	Use(fast)
+
+	stronger := fast
+	stronger.Time = 2
+
+	longer := fast
+	longer.KeyLength = 64
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		want   bool
+	}{
+		{"same parameters", fast, false},
+		{"more passes", stronger, true},
+		{"longer key", longer, true},
+		{"bcrypt", Bcrypt{Cost: 4}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hash, err := test.hasher.Hash("correct horse battery")
+			if err != nil {
+				t.Fatalf("Hash returned an error: %v", err)
+			}
+
+			if got := NeedsRehash(hash); got != test.want {
+				t.Errorf("NeedsRehash(%q) = %v; want %v", hash, got, test.want)
+			}
+		})
+	}
+
+	Use(Bcrypt{Cost: 4})
+	lower, _ := Bcrypt{Cost: 5}.Hash("correct horse battery")
+	if !NeedsRehash(lower) {
+		t.Error("NeedsRehash returned false for a bcrypt hash of another cost")
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	hashes := []string{
+		"",
+		"correct horse battery",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$not base64!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$",
+		"$2a$04$short",
+		"$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5",
+	}
+
+	for _, hash := range hashes {
+		if CheckPasswordHash("correct horse battery", hash) {
+			t.Errorf("CheckPasswordHash accepted %q", hash)
+		}
+		if !NeedsRehash(hash) {
+			t.Errorf("NeedsRehash(%q) = false; want true", hash)
+		}
+	}
+}
+
+func TestDummyCompare(t *testing.T) {
+	defer Use(DefaultArgon2id)
+
+	for _, h := range []Hasher{fast, Bcrypt{Cost: 4}} {
+		Use(h)
+		DummyCompare("anything")
+
+		if !h.Current(dummy) {
+			t.Errorf("dummy hash %q was not made by %T; want the configured hasher's cost", dummy, h)
+		}
+	}
+}
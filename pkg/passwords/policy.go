@@ -0,0 +1,197 @@
+package passwords
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// The rules a password can break, matched with errors.Is.
+var (
+	ErrTooShort  = errors.New("passwords: too short")
+	ErrTooLong   = errors.New("passwords: too long")
+	ErrGuessable = errors.New("passwords: too easy to guess")
+	ErrPersonal  = errors.New("passwords: contains the user's name or email")
+	ErrBreached  = errors.New("passwords: found in a data breach")
+)
+
+// PolicyError is a password that breaks a Policy rule. Its message is
+// written to follow the name of the form field, as in "Password must be at
+// least 12 characters long".
+type PolicyError struct {
+	Rule   error
+	Reason string
+}
+
+func (e *PolicyError) Error() string { return e.Reason }
+func (e *PolicyError) Unwrap() error { return e.Rule }
+
+// Policy decides which passwords are strong enough to set.
+type Policy struct {
+	// MinLength counts characters. MaxLength counts bytes, since that is
+	// what bcrypt is limited by; 0 means no limit.
+	MinLength int
+	MaxLength int
+
+	// MinEntropy is the least estimated strength, in bits, see Entropy.
+	MinEntropy float64
+
+	// Breached, when set, refuses passwords found in a breach.
+	Breached *Breached
+}
+
+// DefaultPolicy is used where nothing is configured.
+var DefaultPolicy = &Policy{MinLength: 12, MaxLength: 128, MinEntropy: 50}
+
+// Check returns a *PolicyError for the first rule password breaks, or nil.
+// personal are the user's email and names, none of which the password may
+// contain.
+func (p *Policy) Check(password string, personal ...string) error {
+	length := utf8.RuneCountInString(password)
+
+	if length < p.MinLength {
+		return &PolicyError{ErrTooShort, fmt.Sprintf("must be at least %d characters long", p.MinLength)}
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		return &PolicyError{ErrTooLong, fmt.Sprintf("must be at most %d characters long", p.MaxLength)}
+	}
+
+	lower := strings.ToLower(password)
+	for _, word := range personalWords(personal) {
+		if strings.Contains(lower, word) {
+			return &PolicyError{ErrPersonal, "must not contain your name or email address"}
+		}
+	}
+
+	if Entropy(password) < p.MinEntropy {
+		return &PolicyError{ErrGuessable, "is too easy to guess; make it longer or mix in other words, numbers and symbols"}
+	}
+
+	if p.Breached != nil {
+		found, err := p.Breached.Contains(password)
+		if err != nil {
+			// A broken list should not stop everyone changing their password
+			log.Printf("Breached password check failed: %v", err)
+		} else if found {
+			return &PolicyError{ErrBreached, "has appeared in a data breach, so attackers will try it; choose another"}
+		}
+	}
+
+	return nil
+}
+
+// Validate is a validator func for the "password" tag. The email and names
+// it keeps out of the password are read from the struct's Email, FirstName
+// and LastName fields, when it has them.
+func (p *Policy) Validate(fl validator.FieldLevel) bool {
+	return p.check(fl) == nil
+}
+
+// Validator is Validate that also stores the error Check returned in err,
+// so a form can explain the failure without checking the password again.
+func (p *Policy) Validator(err *error) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		*err = p.check(fl)
+		return *err == nil
+	}
+}
+
+func (p *Policy) check(fl validator.FieldLevel) error {
+	var personal []string
+
+	parent := reflect.Indirect(fl.Parent())
+	if parent.Kind() == reflect.Struct {
+		for _, name := range []string{"Email", "FirstName", "LastName"} {
+			field := parent.FieldByName(name)
+			if field.IsValid() && field.Kind() == reflect.String {
+				personal = append(personal, field.String())
+			}
+		}
+	}
+
+	return p.Check(fl.Field().String(), personal...)
+}
+
+// personalWords splits the user's details into the lowercase words worth
+// looking for. Very short ones would refuse too many good passwords.
+func personalWords(personal []string) []string {
+	var words []string
+	for _, s := range personal {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if local, _, ok := strings.Cut(s, "@"); ok {
+			s = local
+		}
+
+		for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if utf8.RuneCountInString(word) >= 3 {
+				words = append(words, word)
+			}
+		}
+	}
+	return words
+}
+
+// Entropy estimates the bits of strength in password from the kinds of
+// characters it uses. A character that repeats the one before it, or
+// continues a run such as "abc" or "321", adds only one bit. It is a rough
+// guide that errs high for dictionary words; the breached list covers
+// those.
+func Entropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	bits := math.Log2(float64(pool))
+	total := 0.0
+	prev, step := rune(-1), rune(0)
+
+	for _, r := range password {
+		d := r - prev
+		switch {
+		case prev >= 0 && (d == 0 || (d == 1 || d == -1) && (step == 0 || d == step)):
+			total++
+			step = d
+		default:
+			total += bits
+			step = 0
+		}
+		prev = r
+	}
+
+	return total
+}
@@ -0,0 +1,161 @@
+package passwords
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// breachedList holds "password" and "hunter2", in both file layouts.
+var breachedList = fstest.MapFS{
+	"5BAA6.txt": {Data: []byte("0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:10434004\r\n")},
+	"f3bbb":     {Data: []byte("d66a63d4bf1747940578ec3d0103530e21d:12\n")},
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy := &Policy{MinLength: 12, MaxLength: 72, MinEntropy: 50, Breached: &Breached{Dir: breachedList}}
+	personal := []string{"ada.lovelace@example.com", "Ada", "Lovelace"}
+
+	tests := []struct {
+		name     string
+		password string
+		want     error
+	}{
+		{"long and mixed", "quiet-Otter-71-lantern", nil},
+		{"passphrase", "correct horse battery", nil},
+		{"too short", "Sh0rt!pw", ErrTooShort},
+		{"too long", string(make([]byte, 73)) + "aA1!", ErrTooLong},
+		{"repeated", "aaaaaaaaaaaaaaaa", ErrGuessable},
+		{"run", "abcdefghijklmnop", ErrGuessable},
+		{"digits", "482915736204", ErrGuessable},
+		{"last name", "MrsLOVELACE-1843!", ErrPersonal},
+		{"email local part", "xx-ada.lovelace-xx", ErrPersonal},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := policy.Check(test.password, personal...)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("Check(%q) = %v; want %v", test.password, err, test.want)
+			}
+
+			var policyErr *PolicyError
+			if err != nil && (!errors.As(err, &policyErr) || policyErr.Reason == "") {
+				t.Errorf("Check(%q) = %#v; want a *PolicyError with a reason", test.password, err)
+			}
+		})
+	}
+}
+
+func TestPolicyBreached(t *testing.T) {
+	policy := &Policy{Breached: &Breached{Dir: breachedList}}
+
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"password", ErrBreached},
+		{"hunter2", ErrBreached},
+		{"not on the list", nil},
+	}
+
+	for _, test := range tests {
+		err := policy.Check(test.password)
+		if !errors.Is(err, test.want) {
+			t.Errorf("Check(%q) = %v; want %v", test.password, err, test.want)
+		}
+	}
+}
+
+func TestBreachedPadding(t *testing.T) {
+	list := &Breached{Dir: fstest.MapFS{
+		"5BAA6": {Data: []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:0\n")},
+	}}
+
+	found, err := list.Contains("password")
+	if err != nil {
+		t.Fatalf("Contains returned an error: %v", err)
+	}
+	if found {
+		t.Error("Contains matched a padding line")
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		min, max float64
+	}{
+		{"", 0, 0},
+		{"aaaa", 4.7, 8},
+		{"abcd", 4.7, 8},
+		{"dcba", 4.7, 8},
+		{"qzxv", 18.8, 18.9},
+		{"Qz7!", 26.2, 26.3},
+	}
+
+	for _, test := range tests {
+		got := Entropy(test.password)
+		if got < test.min || got > test.max {
+			t.Errorf("Entropy(%q) = %.2f; want between %.1f and %.1f", test.password, got, test.min, test.max)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterValidation("password", DefaultPolicy.Validate)
+
+	type form struct {
+		FirstName string
+		LastName  string
+		Email     string
+		Password  string `validate:"password"`
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"strong", "quiet-Otter-71-lantern", true},
+		{"short", "Sh0rt!pw", false},
+		{"first name", "Grace-Hopper-1906", false},
+		{"email", "ghopper-navy-1906", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validate.Struct(form{FirstName: "Grace", LastName: "Hopper", Email: "ghopper@example.com", Password: test.password})
+			if got := err == nil; got != test.want {
+				t.Errorf("valid = %v; want %v (err: %v)", got, test.want, err)
+			}
+		})
+	}
+}
+
+func TestPolicyValidator(t *testing.T) {
+	var checked error
+	validate := validator.New()
+	validate.RegisterValidation("password", DefaultPolicy.Validator(&checked))
+
+	type form struct {
+		Password string `validate:"password"`
+	}
+
+	if err := validate.Struct(form{Password: "Sh0rt!pw"}); err == nil {
+		t.Fatal("a short password was valid")
+	}
+	if !errors.Is(checked, ErrTooShort) {
+		t.Errorf("stored error = %v; want %v", checked, ErrTooShort)
+	}
+
+	if err := validate.Struct(form{Password: "quiet-Otter-71-lantern"}); err != nil {
+		t.Fatalf("a strong password was invalid: %v", err)
+	}
+	if checked != nil {
+		t.Errorf("stored error = %v; want nil", checked)
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"html"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// hljsClasses maps chroma token types to the highlight.js class names styled
+// by static/highlight/styles/atom-one-dark.css. Token types without an entry
+// use the class of their nearest parent.
+var hljsClasses = map[chroma.TokenType]string{
+	chroma.Keyword:             "hljs-keyword",
+	chroma.KeywordConstant:     "hljs-literal",
+	chroma.KeywordType:         "hljs-type",
+	chroma.NameAttribute:       "hljs-attr",
+	chroma.NameBuiltin:         "hljs-built_in",
+	chroma.NameClass:           "hljs-title",
+	chroma.NameDecorator:       "hljs-meta",
+	chroma.NameFunction:        "hljs-title",
+	chroma.NameTag:             "hljs-name",
+	chroma.NameVariable:        "hljs-variable",
+	chroma.LiteralString:       "hljs-string",
+	chroma.LiteralStringRegex:  "hljs-regexp",
+	chroma.LiteralStringSymbol: "hljs-symbol",
+	chroma.LiteralNumber:       "hljs-number",
+	chroma.Comment:             "hljs-comment",
+	chroma.CommentPreproc:      "hljs-meta",
+	chroma.GenericDeleted:      "hljs-deletion",
+	chroma.GenericEmph:         "hljs-emphasis",
+	chroma.GenericHeading:      "hljs-section",
+	chroma.GenericInserted:     "hljs-addition",
+	chroma.GenericStrong:       "hljs-strong",
+	chroma.GenericSubheading:   "hljs-section",
+}
+
+func hljsClass(t chroma.TokenType) string {
+	for ; t != 0; t = t.Parent() {
+		if class, ok := hljsClasses[t]; ok {
+			return class
+		}
+	}
+	return ""
+}
+
+// Highlight renders code as a highlight.js compatible code block. The lexer
+// is chosen by lang, the first word of a fence's info string, or guessed from
+// the code when lang is empty. It returns false when no lexer matches.
+func Highlight(code, lang string) (string, bool) {
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	} else {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		return "", false
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", false
+	}
+
+	if lang == "" {
+		lang = lexer.Config().Name
+		if aliases := lexer.Config().Aliases; len(aliases) > 0 {
+			lang = aliases[0]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(`<pre><code class="hljs language-`)
+	b.WriteString(languageClass(lang))
+	b.WriteString(`">`)
+
+	for _, token := range iterator.Tokens() {
+		class := hljsClass(token.Type)
+		if class == "" {
+			b.WriteString(html.EscapeString(token.Value))
+			continue
+		}
+
+		b.WriteString(`<span class="`)
+		b.WriteString(class)
+		b.WriteString(`">`)
+		b.WriteString(html.EscapeString(token.Value))
+		b.WriteString(`</span>`)
+	}
+
+	b.WriteString("</code></pre>\n")
+	return b.String(), true
+}
+
+// highlightHook is a gomarkdown render hook that highlights fenced and
+// indented code blocks, leaving the default rendering for unknown languages.
+func highlightHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	block, ok := node.(*ast.CodeBlock)
+	if !ok {
+		return ast.GoToNext, false
+	}
+
+	var lang string
+	if fields := strings.Fields(string(block.Info)); len(fields) > 0 {
+		lang = fields[0]
+	}
+
+	highlighted, ok := Highlight(string(block.Literal), lang)
+	if !ok {
+		return ast.GoToNext, false
+	}
+
+	io.WriteString(w, highlighted)
+	return ast.GoToNext, true
+}
+
+// languageClass lowercases lang and replaces what the sanitizer's
+// language- class pattern does not allow, such as the spaces in some lexer
+// names, with hyphens.
+func languageClass(lang string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', strings.ContainsRune("_+#-", r):
+			return r
+		}
+		return '-'
+	}, strings.ToLower(lang))
+}
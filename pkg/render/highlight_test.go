@@ -0,0 +1,62 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		lang     string
+		ok       bool
+		contains []string
+	}{
+		{"go", "func main() {}\n", "go", true, []string{`<code class="hljs language-go">`, `<span class="hljs-keyword">func</span>`}},
+		{"escapes", "x := \"<b>\"\n", "go", true, []string{`<span class="hljs-string">&#34;&lt;b&gt;&#34;</span>`}},
+		{"comment", "# note\n", "bash", true, []string{`<span class="hljs-comment"># note</span>`}},
+		{"alias", "SELECT 1;\n", "SQL", true, []string{`<span class="hljs-keyword">SELECT</span>`, `<span class="hljs-number">1</span>`}},
+		{"unknown", "whatever\n", "nope", false, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, ok := Highlight(test.code, test.lang)
+			if ok != test.ok {
+				t.Fatalf("Highlight(%q, %q) ok = %v; want %v", test.code, test.lang, ok, test.ok)
+			}
+			for _, want := range test.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("Highlight(%q, %q) = %q; want it to contain %q", test.code, test.lang, result, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLanguageClass(t *testing.T) {
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"go", "go"},
+		{"C++", "c++"},
+		{"C#", "c#"},
+		{"Objective-C", "objective-c"},
+		{"Bash Session", "bash-session"},
+		{`x"><script>`, "x---script-"},
+	}
+
+	for _, test := range tests {
+		got := languageClass(test.lang)
+		if got != test.want {
+			t.Errorf("languageClass(%q) = %q; want %q", test.lang, got, test.want)
+		}
+
+		block := `<code class="hljs language-` + got + `">x</code>`
+		if sanitized := Policy.Sanitize(block); !strings.Contains(sanitized, "language-") {
+			t.Errorf("the sanitizer dropped the class from %q", block)
+		}
+	}
+}
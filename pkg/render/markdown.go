@@ -0,0 +1,82 @@
+package render
+
+import (
+	"html/template"
+	"regexp"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Format is the value stored in an article's format column.
+type Format string
+
+const (
+	FormatMarkdown    Format = "markdown"
+	FormatMarkdownTOC Format = "markdown+toc"
+)
+
+// MarkdownOptions controls how a markdown format is parsed and rendered.
+type MarkdownOptions struct {
+	Extensions parser.Extensions
+	Flags      html.Flags
+	// Highlight colours code blocks on the server.
+	Highlight bool
+}
+
+// DefaultMarkdownOptions enables tables, footnotes, fenced code, heading IDs
+// and syntax highlighting on top of the gomarkdown defaults.
+var DefaultMarkdownOptions = MarkdownOptions{
+	Extensions: parser.CommonExtensions | parser.Footnotes | parser.AutoHeadingIDs,
+	Flags:      html.CommonFlags | html.HrefTargetBlank,
+	Highlight:  true,
+}
+
+// markdownOptions holds the options for each markdown format.
+var markdownOptions = map[Format]MarkdownOptions{
+	FormatMarkdown: DefaultMarkdownOptions,
+	FormatMarkdownTOC: {
+		Extensions: DefaultMarkdownOptions.Extensions,
+		Flags:      DefaultMarkdownOptions.Flags | html.TOC,
+		Highlight:  DefaultMarkdownOptions.Highlight,
+	},
+}
+
+// OptionsFor returns the markdown options for a format, falling back to the
+// defaults for formats without their own.
+func OptionsFor(format Format) MarkdownOptions {
+	opts, ok := markdownOptions[format]
+	if !ok {
+		return DefaultMarkdownOptions
+	}
+	return opts
+}
+
+// Policy is the sanitizer applied to all rendered markdown. It allows user
+// generated content plus the classes and IDs the markdown renderer emits.
+var Policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(hljs )?language-[\w+#-]+$`)).OnElements("code")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^hljs-[\w-]+$`)).OnElements("span")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^(footnotes|footnote-return)$`)).OnElements("div", "a")
+	p.AllowAttrs("id").Matching(bluemonday.SpaceSeparatedTokens).OnElements("nav")
+	p.AllowAttrs("target").Matching(regexp.MustCompile(`^_blank$`)).OnElements("a")
+	return p
+}
+
+// Markdown renders md to sanitized HTML using the given options.
+func Markdown(md string, opts MarkdownOptions) template.HTML {
+	p := parser.NewWithExtensions(opts.Extensions)
+	rendererOpts := html.RendererOptions{Flags: opts.Flags}
+	if opts.Highlight {
+		rendererOpts.RenderNodeHook = highlightHook
+	}
+	r := html.NewRenderer(rendererOpts)
+
+	unsafe := markdown.ToHTML([]byte(md), p, r)
+	return template.HTML(Policy.SanitizeBytes(unsafe))
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		format   Format
+		contains []string
+		excludes []string
+	}{
+		{"script stripped", "hello <script>alert(1)</script>", FormatMarkdown, []string{"hello"}, []string{"<script", "alert(1)"}},
+		{"event handler stripped", `<img src="/a.png" onerror="alert(1)">`, FormatMarkdown, []string{`src="/a.png"`}, []string{"onerror"}},
+		{"javascript link stripped", "[x](javascript:alert(1))", FormatMarkdown, []string{"x"}, []string{"javascript:"}},
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", FormatMarkdown, []string{"<table>", "<td>1</td>"}, nil},
+		{"fenced code", "```go\nfmt.Println()\n```", FormatMarkdown, []string{`<code class="hljs language-go">`, `<span class="hljs-title">Println</span>`}, nil},
+		{"fenced code unknown language", "```nope\n<b>\n```", FormatMarkdown, []string{`<code class="language-nope">`, "&lt;b&gt;"}, nil},
+		{"heading id", "# Hello World", FormatMarkdown, []string{`<h1 id="hello-world">`}, nil},
+		{"footnote", "text[^1]\n\n[^1]: note", FormatMarkdown, []string{`class="footnotes"`, "note"}, nil},
+		{"external link", "[go](https://go.dev)", FormatMarkdown, []string{`target="_blank"`}, nil},
+		{"no toc by default", "# One\n\n## Two", FormatMarkdown, nil, []string{"<nav"}},
+		{"toc", "# One\n\n## Two", FormatMarkdownTOC, []string{"<nav>", `href="#one"`}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := string(Markdown(test.input, OptionsFor(test.format)))
+			for _, want := range test.contains {
+				if !strings.Contains(result, want) {
+					t.Errorf("Markdown(%q) = %q; want it to contain %q", test.input, result, want)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(result, unwanted) {
+					t.Errorf("Markdown(%q) = %q; want it to not contain %q", test.input, result, unwanted)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsForUnknownFormat(t *testing.T) {
+	if OptionsFor("unknown") != DefaultMarkdownOptions {
+		t.Errorf("OptionsFor(unknown) did not return the default options")
+	}
+}
@@ -0,0 +1,91 @@
+package render
+
+import (
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatHTML Format = "html"
+	FormatText Format = "text"
+)
+
+// Renderer turns an article body into HTML that is safe to serve.
+type Renderer func(body string) template.HTML
+
+// renderers holds a renderer for every format an article may use.
+var renderers = map[Format]Renderer{
+	FormatMarkdown:    markdownRenderer(FormatMarkdown),
+	FormatMarkdownTOC: markdownRenderer(FormatMarkdownTOC),
+	FormatHTML:        renderHTML,
+	FormatText:        renderText,
+}
+
+// Register adds or replaces the renderer for a format.
+func Register(format Format, r Renderer) {
+	renderers[format] = r
+}
+
+// IsRegistered reports whether format has a renderer.
+func IsRegistered(format Format) bool {
+	_, ok := renderers[format]
+	return ok
+}
+
+// Formats returns every registered format in name order.
+func Formats() []Format {
+	formats := make([]Format, 0, len(renderers))
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// Render renders body with the renderer for format. Articles without a
+// format, or with one that is no longer registered, render as markdown.
+func Render(format Format, body string) template.HTML {
+	r, ok := renderers[format]
+	if !ok {
+		r = renderers[FormatMarkdown]
+	}
+	return r(body)
+}
+
+func markdownRenderer(format Format) Renderer {
+	return func(body string) template.HTML {
+		return Markdown(body, OptionsFor(format))
+	}
+}
+
+// renderHTML passes hand written HTML through the sanitizer.
+func renderHTML(body string) template.HTML {
+	return template.HTML(Policy.Sanitize(body))
+}
+
+// renderText escapes plain text, turning blank lines into paragraphs and
+// single newlines into line breaks.
+func renderText(body string) template.HTML {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	var b strings.Builder
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+
+		b.WriteString("<p>")
+		b.WriteString(strings.Join(lines, "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+
+	return template.HTML(b.String())
+}
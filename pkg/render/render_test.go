@@ -0,0 +1,43 @@
+package render
+
+import (
+	"html/template"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   Format
+		input    string
+		expected template.HTML
+	}{
+		{"markdown", FormatMarkdown, "*hi*", "<p><em>hi</em></p>\n"},
+		{"empty format", "", "*hi*", "<p><em>hi</em></p>\n"},
+		{"unknown format", "asciidoc", "*hi*", "<p><em>hi</em></p>\n"},
+		{"html", FormatHTML, `<p onclick="x()">hi</p><script>x()</script>`, "<p>hi</p>"},
+		{"text", FormatText, "a <b>\nline\n\nnext *para*", "<p>a &lt;b&gt;<br>\nline</p>\n<p>next *para*</p>\n"},
+		{"text blank", FormatText, "\n\n\n", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Render(test.format, test.input)
+			if result != test.expected {
+				t.Errorf("Render(%q, %q) = %q; want %q", test.format, test.input, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestIsRegistered(t *testing.T) {
+	for _, format := range Formats() {
+		if !IsRegistered(format) {
+			t.Errorf("IsRegistered(%q) = false for a listed format", format)
+		}
+	}
+
+	if IsRegistered("asciidoc") {
+		t.Errorf("IsRegistered(asciidoc) = true; want false")
+	}
+}
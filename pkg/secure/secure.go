@@ -0,0 +1,174 @@
+package secure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// NoncePlaceholder stands in for the nonce in the default policy and in
+// pages rendered ahead of time, such as cached articles.
+const NoncePlaceholder = "{nonce}"
+
+// DefaultPolicy allows scripts from this origin and inline scripts carrying
+// the request's nonce.
+const DefaultPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; " +
+	"style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self' data:; " +
+	"object-src 'none'; base-uri 'self'; form-action 'self'"
+
+type contextKey struct{}
+
+// Config holds the header values. An empty value leaves that header out.
+type Config struct {
+	// Policy is the Content-Security-Policy, with NoncePlaceholder replaced
+	// by each request's nonce.
+	Policy string
+	// ReportOnly sends Policy as Content-Security-Policy-Report-Only so
+	// violations are reported but nothing is blocked.
+	ReportOnly bool
+	// ReportURI is added to the policy as its report-uri.
+	ReportURI string
+	// FrameAncestors is added to the policy as its frame-ancestors.
+	FrameAncestors string
+	// HSTS is the Strict-Transport-Security value, sent on HTTPS requests.
+	HSTS              string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+}
+
+// ConfigFromEnv reads the SECURITY_* variables, using the defaults for any
+// that are unset.
+func ConfigFromEnv() Config {
+	return Config{
+		Policy:            env("SECURITY_CSP", DefaultPolicy),
+		ReportOnly:        os.Getenv("SECURITY_CSP_REPORT_ONLY") == "true",
+		ReportURI:         os.Getenv("SECURITY_CSP_REPORT_URI"),
+		FrameAncestors:    env("SECURITY_FRAME_ANCESTORS", "'self'"),
+		HSTS:              env("SECURITY_HSTS", "max-age=31536000; includeSubDomains"),
+		ReferrerPolicy:    env("SECURITY_REFERRER_POLICY", "strict-origin-when-cross-origin"),
+		PermissionsPolicy: env("SECURITY_PERMISSIONS_POLICY", "camera=(), microphone=(), geolocation=(), payment=()"),
+	}
+}
+
+func env(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// Headers sets the security headers on every response.
+type Headers struct {
+	config Config
+	policy string
+}
+
+// New returns Headers for config.
+func New(config Config) *Headers {
+	policy := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(config.Policy), ";"))
+	if policy != "" {
+		if config.FrameAncestors != "" {
+			policy += "; frame-ancestors " + config.FrameAncestors
+		}
+		if config.ReportURI != "" {
+			policy += "; report-uri " + config.ReportURI
+		}
+	}
+
+	return &Headers{config: config, policy: policy}
+}
+
+// Nonce returns the request's CSP nonce, or an empty string when the
+// middleware is not in use.
+func Nonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(contextKey{}).(string)
+	return nonce
+}
+
+// Handler adds a nonce to the request context and the headers to the
+// response. The policy is left off 304 responses so a browser keeps the
+// one matching the nonces in the page it already has.
+func (h *Headers) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce, err := newNonce()
+		if err != nil {
+			http.Error(w, "Unable to generate a nonce", http.StatusInternalServerError)
+			return
+		}
+
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		if h.config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", h.config.ReferrerPolicy)
+		}
+		if h.config.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", h.config.PermissionsPolicy)
+		}
+		if h.config.HSTS != "" && isHTTPS(r) {
+			header.Set("Strict-Transport-Security", h.config.HSTS)
+		}
+
+		sw := &writer{ResponseWriter: w}
+		if h.policy != "" {
+			sw.name = "Content-Security-Policy"
+			if h.config.ReportOnly {
+				sw.name = "Content-Security-Policy-Report-Only"
+			}
+			sw.policy = strings.ReplaceAll(h.policy, NoncePlaceholder, nonce)
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, nonce)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+	})
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// isHTTPS reports whether the client connected over TLS, directly or
+// through a proxy that says so.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// writer sets the policy header when the status is known.
+type writer struct {
+	http.ResponseWriter
+	name        string
+	policy      string
+	wroteHeader bool
+}
+
+func (w *writer) WriteHeader(status int) {
+	if !w.wroteHeader && status >= http.StatusOK {
+		w.wroteHeader = true
+		if w.policy != "" && status != http.StatusNotModified {
+			w.Header().Set(w.name, w.policy)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,128 @@
+package secure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(h *Headers, r *http.Request) (*httptest.ResponseRecorder, string) {
+	var nonce string
+	handler := h.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = Nonce(r)
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec, nonce
+}
+
+func TestHandler(t *testing.T) {
+	h := New(Config{
+		Policy:            DefaultPolicy,
+		FrameAncestors:    "'none'",
+		ReportURI:         "/csp-report",
+		HSTS:              "max-age=60",
+		ReferrerPolicy:    "no-referrer",
+		PermissionsPolicy: "camera=()",
+	})
+
+	rec, nonce := serve(h, httptest.NewRequest("GET", "/", nil))
+	if nonce == "" {
+		t.Fatalf("no nonce in the request context")
+	}
+
+	csp := rec.Header().Get("Content-Security-Policy")
+	for _, want := range []string{"'nonce-" + nonce + "'", "; frame-ancestors 'none'", "; report-uri /csp-report"} {
+		if !strings.Contains(csp, want) {
+			t.Errorf("Content-Security-Policy = %q; want it to contain %q", csp, want)
+		}
+	}
+	if strings.Contains(csp, NoncePlaceholder) {
+		t.Errorf("Content-Security-Policy = %q; placeholder was not replaced", csp)
+	}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"X-Content-Type-Options", "nosniff"},
+		{"Referrer-Policy", "no-referrer"},
+		{"Permissions-Policy", "camera=()"},
+		{"Strict-Transport-Security", ""},
+		{"Content-Security-Policy-Report-Only", ""},
+	}
+
+	for _, test := range tests {
+		if got := rec.Header().Get(test.header); got != test.want {
+			t.Errorf("%s = %q; want %q", test.header, got, test.want)
+		}
+	}
+
+	_, again := serve(h, httptest.NewRequest("GET", "/", nil))
+	if again == nonce {
+		t.Errorf("two requests got the same nonce %q", nonce)
+	}
+}
+
+func TestHandlerHSTS(t *testing.T) {
+	h := New(Config{HSTS: "max-age=60"})
+
+	tests := []struct {
+		name  string
+		proto string
+		want  string
+	}{
+		{"plain http", "", ""},
+		{"forwarded https", "https", "max-age=60"},
+		{"forwarded http", "http", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+
+			rec, _ := serve(h, r)
+			if got := rec.Header().Get("Strict-Transport-Security"); got != test.want {
+				t.Errorf("Strict-Transport-Security = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHandlerReportOnly(t *testing.T) {
+	h := New(Config{Policy: "default-src 'self';", ReportOnly: true})
+
+	rec, _ := serve(h, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Content-Security-Policy = %q; want none in report-only mode", got)
+	}
+	if got := rec.Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'" {
+		t.Errorf("Content-Security-Policy-Report-Only = %q; want %q", got, "default-src 'self'")
+	}
+}
+
+func TestHandlerEmptyConfig(t *testing.T) {
+	rec, _ := serve(New(Config{}), httptest.NewRequest("GET", "/", nil))
+
+	for _, header := range []string{"Content-Security-Policy", "Referrer-Policy", "Permissions-Policy"} {
+		if got := rec.Header().Get(header); got != "" {
+			t.Errorf("%s = %q; want none", header, got)
+		}
+	}
+	if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q; want %q", got, "nosniff")
+	}
+}
+
+func TestNonceWithoutMiddleware(t *testing.T) {
+	if got := Nonce(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("Nonce = %q; want an empty string", got)
+	}
+}
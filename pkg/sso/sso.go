@@ -0,0 +1,184 @@
+// Package sso signs users in with an OpenID Connect identity provider. It
+// runs the authorization code flow with PKCE and checks the ID token's
+// signature, issuer, audience, expiry and nonce before trusting who it
+// says the user is.
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// ErrState is returned by Finish when the callback does not belong to
+	// the login started on this session.
+	ErrState = errors.New("sso: state does not match")
+
+	// ErrUnverifiedEmail is returned by Finish when the provider does not
+	// vouch for the user's email address, which is what maps them to an
+	// account.
+	ErrUnverifiedEmail = errors.New("sso: email address is not verified")
+)
+
+// Config is a client registered with the provider at Issuer.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Provider is an identity provider found by discovery.
+type Provider struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// Identity is who the provider says signed in.
+type Identity struct {
+	Issuer     string
+	Subject    string
+	Email      string
+	GivenName  string
+	FamilyName string
+	Name       string
+}
+
+// flow is what Finish needs from Begin, kept in the user's session.
+type flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// New looks up the provider's endpoints and keys from its discovery
+// document.
+func New(ctx context.Context, config Config) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// Begin returns the provider URL to send the user to, and the state to keep
+// in their session for Finish.
+func (p *Provider) Begin() (string, string, error) {
+	f := flow{Verifier: oauth2.GenerateVerifier()}
+
+	var err error
+	if f.State, err = random(); err != nil {
+		return "", "", err
+	}
+	if f.Nonce, err = random(); err != nil {
+		return "", "", err
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL := p.oauth2.AuthCodeURL(f.State, oidc.Nonce(f.Nonce), oauth2.S256ChallengeOption(f.Verifier))
+	return authURL, string(b), nil
+}
+
+// Finish handles the provider's redirect back with query, exchanging its
+// code for tokens and returning the identity in the ID token.
+func (p *Provider) Finish(ctx context.Context, state string, query url.Values) (Identity, error) {
+	var f flow
+	if state == "" || json.Unmarshal([]byte(state), &f) != nil {
+		return Identity{}, ErrState
+	}
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(f.State)) != 1 {
+		return Identity{}, ErrState
+	}
+
+	if e := query.Get("error"); e != "" {
+		return Identity{}, fmt.Errorf("sso: provider returned %s: %s", e, query.Get("error_description"))
+	}
+
+	token, err := p.oauth2.Exchange(ctx, query.Get("code"), oauth2.VerifierOption(f.Verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: code exchange failed: %v", err)
+	}
+
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.New("sso: no id_token in token response")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, raw)
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: invalid id_token: %v", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(f.Nonce)) != 1 {
+		return Identity{}, errors.New("sso: id_token nonce does not match")
+	}
+
+	var claims struct {
+		Email         string   `json:"email"`
+		EmailVerified verified `json:"email_verified"`
+		GivenName     string   `json:"given_name"`
+		FamilyName    string   `json:"family_name"`
+		Name          string   `json:"name"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return Identity{}, fmt.Errorf("sso: invalid id_token claims: %v", err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return Identity{}, ErrUnverifiedEmail
+	}
+
+	return Identity{
+		Issuer:     idToken.Issuer,
+		Subject:    idToken.Subject,
+		Email:      claims.Email,
+		GivenName:  claims.GivenName,
+		FamilyName: claims.FamilyName,
+		Name:       claims.Name,
+	}, nil
+}
+
+// verified reads email_verified, which some providers send as a string.
+type verified bool
+
+func (v *verified) UnmarshalJSON(b []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	*v = value == true || value == "true"
+	return nil
+}
+
+func random() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,295 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// mockProvider is a minimal OpenID Connect provider. Its authorization
+// endpoint signs everyone in as the same user and hands back a code bound
+// to the PKCE challenge and nonce it was sent.
+type mockProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+	mu     sync.Mutex
+	grants map[string]url.Values
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockProvider{key: key, grants: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", m.jwks)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	m.claims = map[string]interface{}{
+		"sub":            "248289761001",
+		"aud":            "client",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	}
+
+	return m
+}
+
+func (m *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                m.URL,
+		"authorization_endpoint":                m.URL + "/authorize",
+		"token_endpoint":                        m.URL + "/token",
+		"jwks_uri":                              m.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (m *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != "client" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := make([]byte, 16)
+	rand.Read(code)
+
+	m.mu.Lock()
+	m.grants[base64.RawURLEncoding.EncodeToString(code)] = query
+	m.mu.Unlock()
+
+	redirect, _ := url.Parse(query.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{
+		"code":  {base64.RawURLEncoding.EncodeToString(code)},
+		"state": {query.Get("state")},
+	}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	id, secret, _ := r.BasicAuth()
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || id != "client" || secret != "secret" ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != grant.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss":   m.URL,
+		"nonce": grant.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: m.key, KeyID: "test"}}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idToken, _ := signed.CompactSerialize()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func (m *mockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &m.key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+	}})
+}
+
+// login runs the flow up to the callback, letting change alter the state
+// and callback query first.
+func login(t *testing.T, p *Provider, change func(state *string, query url.Values)) (Identity, error) {
+	t.Helper()
+
+	authURL, state, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize returned %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	query := callback.Query()
+	if change != nil {
+		change(&state, query)
+	}
+
+	return p.Finish(context.Background(), state, query)
+}
+
+func newTestProvider(t *testing.T, m *mockProvider) *Provider {
+	p, err := New(context.Background(), Config{
+		Issuer:       m.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://example.com/admin/login/sso/callback",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return p
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		change  func(state *string, query url.Values)
+		ok      bool
+		wantErr error
+	}{
+		{name: "ok", ok: true},
+		{name: "email_verified as a string", claims: map[string]interface{}{"email_verified": "true"}, ok: true},
+		{name: "unverified email", claims: map[string]interface{}{"email_verified": false}, wantErr: ErrUnverifiedEmail},
+		{name: "no email", claims: map[string]interface{}{"email": ""}, wantErr: ErrUnverifiedEmail},
+		{name: "another audience", claims: map[string]interface{}{"aud": "someone-else"}},
+		{name: "expired", claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{name: "another nonce", claims: map[string]interface{}{"nonce": "forged"}},
+		{name: "another issuer", claims: map[string]interface{}{"iss": "https://evil.example.com"}},
+		{
+			name:    "state from another login",
+			change:  func(_ *string, query url.Values) { query.Set("state", "forged") },
+			wantErr: ErrState,
+		},
+		{
+			name:    "no login started",
+			change:  func(state *string, _ url.Values) { *state = "" },
+			wantErr: ErrState,
+		},
+		{
+			name: "wrong PKCE verifier",
+			change: func(state *string, _ url.Values) {
+				var f flow
+				json.Unmarshal([]byte(*state), &f)
+				f.Verifier = "forged-verifier-that-is-long-enough-to-pass-the-length-check"
+				b, _ := json.Marshal(f)
+				*state = string(b)
+			},
+		},
+		{
+			name: "provider error",
+			change: func(_ *string, query url.Values) {
+				query.Del("code")
+				query.Set("error", "access_denied")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			for k, v := range tt.claims {
+				m.claims[k] = v
+			}
+
+			identity, err := login(t, newTestProvider(t, m), tt.change)
+
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Finish: %v", err)
+				}
+				want := Identity{Issuer: m.URL, Subject: "248289761001", Email: "jane@example.com", GivenName: "Jane", FamilyName: "Doe"}
+				if identity != want {
+					t.Errorf("Identity = %+v, want %+v", identity, want)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Finish succeeded with %+v, want an error", identity)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBegin(t *testing.T) {
+	m := newMockProvider(t)
+	p := newTestProvider(t, m)
+
+	first, _, err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, _ := p.Begin()
+
+	u, _ := url.Parse(first)
+	query := u.Query()
+	for _, param := range []string{"state", "nonce", "code_challenge"} {
+		if query.Get(param) == "" {
+			t.Errorf("authorization URL has no %s: %s", param, first)
+		}
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+
+	u, _ = url.Parse(second)
+	if u.Query().Get("state") == query.Get("state") || u.Query().Get("nonce") == query.Get("nonce") {
+		t.Error("two logins got the same state or nonce")
+	}
+}
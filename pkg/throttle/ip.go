@@ -0,0 +1,26 @@
+package throttle
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the address a request came from. X-Real-IP is trusted
+// only when the connection itself comes from a loopback or private address,
+// that is from the proxy in front of the app.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remote := net.ParseIP(host)
+	if remote != nil && (remote.IsLoopback() || remote.IsPrivate()) {
+		if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+			return real
+		}
+	}
+
+	return host
+}
@@ -0,0 +1,81 @@
+package throttle
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis is a Store backed by a Redis connection pool, such as the one owned
+// by the session store. Keys are namespaced by Prefix.
+type Redis struct {
+	Pool   *redis.Pool
+	Prefix string
+}
+
+func (s *Redis) Incr(key string, ttl time.Duration) (int, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("INCR", s.Prefix+key))
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 1 {
+		if _, err := conn.Do("PEXPIRE", s.Prefix+key, ttl.Milliseconds()); err != nil {
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+func (s *Redis) Get(key string) (int, time.Duration, error) {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	conn.Send("MULTI")
+	conn.Send("GET", s.Prefix+key)
+	conn.Send("PTTL", s.Prefix+key)
+	values, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	value, err := redis.Int(values[0], nil)
+	if err == redis.ErrNil {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ttl, err := redis.Int64(values[1], nil)
+	if err != nil || ttl < 0 {
+		return value, 0, err
+	}
+
+	return value, time.Duration(ttl) * time.Millisecond, nil
+}
+
+func (s *Redis) Set(key string, value int, ttl time.Duration) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", s.Prefix+key, value, "PX", ttl.Milliseconds())
+	return err
+}
+
+func (s *Redis) Delete(keys ...string) error {
+	conn := s.Pool.Get()
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = s.Prefix + key
+	}
+
+	_, err := conn.Do("DEL", args...)
+	return err
+}
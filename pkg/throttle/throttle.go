@@ -0,0 +1,193 @@
+package throttle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store keeps counters that expire on their own.
+type Store interface {
+	// Incr adds one to key and returns the new value. A key that did not
+	// exist starts its ttl.
+	Incr(key string, ttl time.Duration) (int, error)
+	// Get returns the value of key and how long it has left, or zeros if
+	// it does not exist.
+	Get(key string) (int, time.Duration, error)
+	// Set stores value at key for ttl.
+	Set(key string, value int, ttl time.Duration) error
+	Delete(keys ...string) error
+}
+
+// Block says why an attempt was refused and when to try again.
+type Block struct {
+	Reason     string
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (b *Block) Error() string {
+	return fmt.Sprintf("%s; retry after %s", b.Reason, b.RetryAfter)
+}
+
+// Limiter slows down repeated login failures. Each failure for an account
+// makes it wait twice as long before the next attempt, up to DelayMax, and
+// LockAfter failures lock it for LockFor. Independently, an IP address is
+// refused for the rest of Window once it has failed IPLimit times, whatever
+// accounts it tried.
+type Limiter struct {
+	Store     Store
+	IPLimit   int
+	Window    time.Duration
+	LockAfter int
+	LockFor   time.Duration
+	DelayBase time.Duration
+	DelayMax  time.Duration
+}
+
+// New returns a Limiter with the defaults: 20 failures per IP in 15
+// minutes, delays from 1 to 30 seconds, and a 15 minute lock after 5
+// failures.
+func New(store Store) *Limiter {
+	return &Limiter{
+		Store:     store,
+		IPLimit:   20,
+		Window:    15 * time.Minute,
+		LockAfter: 5,
+		LockFor:   15 * time.Minute,
+		DelayBase: time.Second,
+		DelayMax:  30 * time.Second,
+	}
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func failuresKey(account string) string {
+	return "failures:" + normalize(account)
+}
+
+func waitKey(account string) string {
+	return "wait:" + normalize(account)
+}
+
+func lockKey(account string) string {
+	return "lock:" + normalize(account)
+}
+
+// normalize makes accounts that differ only by case or surrounding space
+// share their counters.
+func normalize(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+// Check returns a Block if a login for account from ip must be refused. An
+// empty account, for logins that do not name one, only checks the address.
+func (l *Limiter) Check(ip, account string) (*Block, error) {
+	anonymous := normalize(account) == ""
+
+	if !anonymous {
+		_, left, err := l.Store.Get(lockKey(account))
+		if err != nil {
+			return nil, err
+		}
+		if left > 0 {
+			return &Block{Reason: "account locked", RetryAfter: left, Locked: true}, nil
+		}
+	}
+
+	if l.IPLimit > 0 {
+		count, left, err := l.Store.Get(ipKey(ip))
+		if err != nil {
+			return nil, err
+		}
+		if count >= l.IPLimit && left > 0 {
+			return &Block{Reason: "too many attempts from this address", RetryAfter: left}, nil
+		}
+	}
+
+	if anonymous {
+		return nil, nil
+	}
+
+	_, left, err := l.Store.Get(waitKey(account))
+	if err != nil {
+		return nil, err
+	}
+	if left > 0 {
+		return &Block{Reason: "too many attempts", RetryAfter: left}, nil
+	}
+
+	return nil, nil
+}
+
+// Fail records a failed login and reports whether it locked the account.
+// With an empty account only the address is counted.
+func (l *Limiter) Fail(ip, account string) (bool, error) {
+	if l.IPLimit > 0 {
+		if _, err := l.Store.Incr(ipKey(ip), l.Window); err != nil {
+			return false, err
+		}
+	}
+
+	if normalize(account) == "" {
+		return false, nil
+	}
+
+	failures, err := l.Store.Incr(failuresKey(account), l.LockFor)
+	if err != nil {
+		return false, err
+	}
+
+	if l.LockAfter > 0 && failures >= l.LockAfter {
+		if err := l.Store.Set(lockKey(account), failures, l.LockFor); err != nil {
+			return false, err
+		}
+		if err := l.Store.Delete(failuresKey(account), waitKey(account)); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if delay := l.delay(failures); delay > 0 {
+		if err := l.Store.Set(waitKey(account), failures, delay); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// delay is the wait after the given number of consecutive failures.
+func (l *Limiter) delay(failures int) time.Duration {
+	if l.DelayBase <= 0 || failures <= 0 {
+		return 0
+	}
+
+	delay := l.DelayBase
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if l.DelayMax > 0 && delay >= l.DelayMax {
+			return l.DelayMax
+		}
+	}
+
+	return delay
+}
+
+// Succeed clears an account's failures after a successful login.
+func (l *Limiter) Succeed(account string) error {
+	return l.Store.Delete(failuresKey(account), waitKey(account))
+}
+
+// Locked returns how long an account stays locked, or zero.
+func (l *Limiter) Locked(account string) (time.Duration, error) {
+	_, left, err := l.Store.Get(lockKey(account))
+	return left, err
+}
+
+// Unlock lifts an account's lock and clears its failures.
+func (l *Limiter) Unlock(account string) error {
+	return l.Store.Delete(lockKey(account), failuresKey(account), waitKey(account))
+}
@@ -0,0 +1,219 @@
+package throttle
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memory is a Store whose clock the tests move by hand.
+type memory struct {
+	now     time.Time
+	values  map[string]int
+	expires map[string]time.Time
+}
+
+func newMemory() *memory {
+	return &memory{now: time.Unix(0, 0), values: map[string]int{}, expires: map[string]time.Time{}}
+}
+
+func (m *memory) live(key string) bool {
+	if m.now.Before(m.expires[key]) {
+		return true
+	}
+	delete(m.values, key)
+	delete(m.expires, key)
+	return false
+}
+
+func (m *memory) Incr(key string, ttl time.Duration) (int, error) {
+	if !m.live(key) {
+		m.expires[key] = m.now.Add(ttl)
+	}
+	m.values[key]++
+	return m.values[key], nil
+}
+
+func (m *memory) Get(key string) (int, time.Duration, error) {
+	if !m.live(key) {
+		return 0, 0, nil
+	}
+	return m.values[key], m.expires[key].Sub(m.now), nil
+}
+
+func (m *memory) Set(key string, value int, ttl time.Duration) error {
+	m.values[key] = value
+	m.expires[key] = m.now.Add(ttl)
+	return nil
+}
+
+func (m *memory) Delete(keys ...string) error {
+	for _, key := range keys {
+		delete(m.values, key)
+		delete(m.expires, key)
+	}
+	return nil
+}
+
+func TestLimiterDelays(t *testing.T) {
+	store := newMemory()
+	l := New(store)
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if _, err := l.Fail("10.0.0.1", "user@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		block, _ := l.Check("10.0.0.1", "User@Example.com ")
+		if block == nil || block.RetryAfter != want {
+			t.Fatalf("after failure %d Check = %v; want a wait of %s", i+1, block, want)
+		}
+
+		store.now = store.now.Add(want)
+		if block, _ := l.Check("10.0.0.1", "user@example.com"); block != nil {
+			t.Fatalf("after waiting %s Check = %v; want nil", want, block)
+		}
+	}
+
+	if err := l.Succeed("user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	l.Fail("10.0.0.1", "user@example.com")
+	if block, _ := l.Check("10.0.0.1", "user@example.com"); block == nil || block.RetryAfter != time.Second {
+		t.Errorf("after success the delay was not reset: %v", block)
+	}
+}
+
+func TestLimiterLock(t *testing.T) {
+	store := newMemory()
+	l := New(store)
+	l.DelayBase = 0
+
+	for i := 1; i <= l.LockAfter; i++ {
+		locked, err := l.Fail("10.0.0.1", "user@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != (i == l.LockAfter) {
+			t.Fatalf("failure %d locked = %v", i, locked)
+		}
+	}
+
+	block, _ := l.Check("10.0.0.2", "user@example.com")
+	if block == nil || !block.Locked || block.RetryAfter != l.LockFor {
+		t.Fatalf("Check = %v; want a lock of %s from any address", block, l.LockFor)
+	}
+	if left, _ := l.Locked("user@example.com"); left != l.LockFor {
+		t.Errorf("Locked = %s; want %s", left, l.LockFor)
+	}
+
+	if err := l.Unlock("USER@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if block, _ := l.Check("10.0.0.1", "user@example.com"); block != nil {
+		t.Errorf("after Unlock Check = %v; want nil", block)
+	}
+
+	for i := 1; i <= l.LockAfter; i++ {
+		l.Fail("10.0.0.1", "user@example.com")
+	}
+	store.now = store.now.Add(l.LockFor)
+	if block, _ := l.Check("10.0.0.1", "user@example.com"); block != nil {
+		t.Errorf("after LockFor Check = %v; want nil", block)
+	}
+}
+
+func TestLimiterIP(t *testing.T) {
+	store := newMemory()
+	l := New(store)
+	l.IPLimit = 3
+	l.DelayBase = 0
+
+	for _, account := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		l.Fail("10.0.0.1", account)
+	}
+
+	block, _ := l.Check("10.0.0.1", "d@example.com")
+	if block == nil || block.Locked || block.RetryAfter != l.Window {
+		t.Fatalf("Check = %v; want the address refused for %s", block, l.Window)
+	}
+	if block, _ := l.Check("10.0.0.2", "d@example.com"); block != nil {
+		t.Errorf("another address Check = %v; want nil", block)
+	}
+
+	store.now = store.now.Add(l.Window)
+	if block, _ := l.Check("10.0.0.1", "d@example.com"); block != nil {
+		t.Errorf("after Window Check = %v; want nil", block)
+	}
+}
+
+func TestLimiterAnonymous(t *testing.T) {
+	store := newMemory()
+	l := New(store)
+	l.IPLimit = 3
+
+	for i := 0; i < l.LockAfter; i++ {
+		if locked, _ := l.Fail("10.0.0.1", ""); locked {
+			t.Fatalf("Fail %d without an account locked it", i+1)
+		}
+	}
+
+	if block, _ := l.Check("10.0.0.2", ""); block != nil {
+		t.Errorf("another address Check = %v; want nil", block)
+	}
+	if block, _ := l.Check("10.0.0.1", ""); block == nil || block.Locked {
+		t.Errorf("Check = %v; want the address refused", block)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	l := &Limiter{DelayBase: time.Second, DelayMax: 30 * time.Second}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{6, 30 * time.Second},
+		{100, 30 * time.Second},
+	}
+
+	for _, test := range tests {
+		if got := l.delay(test.failures); got != test.want {
+			t.Errorf("delay(%d) = %s; want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		real   string
+		want   string
+	}{
+		{"direct", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"direct ignores header", "203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"behind proxy", "172.18.0.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"loopback proxy", "127.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"proxy without header", "172.18.0.3:1234", "", "172.18.0.3"},
+		{"proxy with junk header", "172.18.0.3:1234", "nope", "172.18.0.3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/login", nil)
+			r.RemoteAddr = test.remote
+			if test.real != "" {
+				r.Header.Set("X-Real-IP", test.real)
+			}
+
+			if got := ClientIP(r); got != test.want {
+				t.Errorf("ClientIP = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
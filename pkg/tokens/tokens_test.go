@@ -0,0 +1,55 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSigner(t *testing.T) {
+	s := New([]byte("test-key"))
+
+	token, hash, err := s.Generate("invite")
+	if err != nil {
+		t.Fatalf("Generate returned an error: %v", err)
+	}
+
+	other, _, _ := s.Generate("invite")
+	if other == token {
+		t.Errorf("two tokens were the same")
+	}
+
+	tampered := []byte(token)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		purpose string
+		token   string
+		ok      bool
+	}{
+		{"valid", s, "invite", token, true},
+		{"other purpose", s, "reset", token, false},
+		{"other key", New([]byte("other-key")), "invite", token, false},
+		{"tampered", s, "invite", string(tampered), false},
+		{"truncated", s, "invite", token[:len(token)-4], false},
+		{"not base64", s, "invite", strings.Repeat("!", len(token)), false},
+		{"empty", s, "invite", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.signer.Verify(test.purpose, test.token)
+			if ok != test.ok {
+				t.Fatalf("Verify ok = %v; want %v", ok, test.ok)
+			}
+			if ok && got != hash {
+				t.Errorf("Verify hash = %q; want %q", got, hash)
+			}
+		})
+	}
+}
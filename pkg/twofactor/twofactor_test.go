@@ -0,0 +1,88 @@
+package twofactor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestValidate(t *testing.T) {
+	secret := "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	counter := now.Unix() / 30
+
+	code := func(at time.Time) string {
+		c, err := totp.GenerateCode(secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name string
+		code string
+		last int64
+		want int64
+		ok   bool
+	}{
+		{"current", code(now), 0, counter, true},
+		{"previous period", code(now.Add(-Period)), 0, counter - 1, true},
+		{"next period", code(now.Add(Period)), 0, counter + 1, true},
+		{"too old", code(now.Add(-2 * Period)), 0, 0, false},
+		{"already used", code(now), counter, 0, false},
+		{"with spaces", " " + code(now) + " ", 0, counter, true},
+		{"wrong", "000000", 0, 0, false},
+		{"short", "123", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := Validate(secret, test.code, test.last, now)
+			if ok != test.ok || got != test.want {
+				t.Errorf("Validate = %d, %v; want %d, %v", got, ok, test.want, test.ok)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := RecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("RecoveryCodes returned an error: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not in the form xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeated", code)
+		}
+		seen[code] = true
+	}
+
+	hash := HashRecoveryCode(codes[0])
+	loose := strings.ToUpper(strings.Replace(codes[0], "-", " ", 1))
+	if HashRecoveryCode(loose) != hash {
+		t.Errorf("HashRecoveryCode(%q) differs from HashRecoveryCode(%q)", loose, codes[0])
+	}
+	if HashRecoveryCode(codes[1]) == hash {
+		t.Errorf("two codes hash the same")
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	key, _ := NewKey("Test", "ada@example.com")
+
+	url, err := QRCode(key)
+	if err != nil {
+		t.Fatalf("QRCode returned an error: %v", err)
+	}
+	if !strings.HasPrefix(string(url), "data:image/png;base64,") {
+		t.Errorf("QRCode = %.40q; want a PNG data URL", url)
+	}
+}
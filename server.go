@@ -2,24 +2,42 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"flag"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/boj/redistore"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jasonsnider/com.jasonsnider.go/admin"
 	"github.com/jasonsnider/com.jasonsnider.go/api/v1"
+	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/assets"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/cache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/fileserver"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/mail"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/passwords"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/throttle"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/tokens"
+	"github.com/jasonsnider/com.jasonsnider.go/static"
+	"github.com/jasonsnider/com.jasonsnider.go/templates"
 	"github.com/jasonsnider/com.jasonsnider.go/web"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
 
-	mode := flag.String("mode", "server", "Mode of operation: server, hash, check")
+	mode := flag.String("mode", "server", "Mode of operation: server, migrate, hash, check")
 	password := flag.String("password", "", "The password to hash or check")
 	hashValue := flag.String("hashvalue", "", "The hash to check the password against")
 	flag.Parse()
@@ -29,10 +47,15 @@ func main() {
 		if err := runServer(); err != nil {
 			log.Fatalf("Application error: %v", err)
 		}
+	case "migrate":
+		if err := runMigrate(); err != nil {
+			log.Fatalf("Migration error: %v", err)
+		}
 	case "hash":
 		if *password == "" {
 			log.Fatal("Please provide a password using the -password flag")
 		}
+		passwords.Use(newHasher())
 		hashPassword(*password)
 	case "check":
 		if *password == "" || *hashValue == "" {
@@ -59,9 +82,47 @@ func runServer() error {
 	}
 	defer dbpool.Close()
 
+	files, err := staticFiles()
+	if err != nil {
+		return err
+	}
+
+	// Fingerprint the built assets, fonts and icons so they can be cached
+	// forever
+	var manifest assets.Set
+	for _, dir := range []string{"dist", "fonts", "fontawesome"} {
+		m, err := assets.Load(assetsFS(files, dir), "/"+dir)
+		if err != nil {
+			return fmt.Errorf("unable to load asset manifest for %s: %v", dir, err)
+		}
+		manifest = append(manifest, m)
+	}
+
+	// Parse every template up front so a syntax error stops the boot
+	funcs := template.FuncMap{"path": manifest.Path}
+	tmpl, err := templates.NewRegistry(os.Getenv("TEMPLATES_DIR"), os.Getenv("THEME_DIR"), os.Getenv("APP_ENV") == "development", funcs)
+	if err != nil {
+		return fmt.Errorf("unable to parse templates: %v", err)
+	}
+
+	store, err := redistore.NewRediStore(10, "tcp", "redis:6379", "", []byte("your-secret-key"))
+	if err != nil {
+		return fmt.Errorf("failed to initialize Redis store: %v", err)
+	}
+	defer store.Close()
+
+	hasher := newHasher()
+	passwords.Use(hasher)
+	policy := newPasswordPolicy(hasher)
+
+	pages := newRenderCache(store)
+	limiter := newLoginLimiter(store)
+	mailer := mail.FromEnv()
+	signer := tokens.New(tokenSecret())
+
 	apiRouter := api.APIRouter(dbpool)
-	webRouter := web.WebRouter(dbpool)
-	adminRouter := admin.AdminRouter(dbpool)
+	webRouter := web.WebRouter(dbpool, tmpl, store, pages)
+	adminRouter := admin.AdminRouter(dbpool, tmpl, store, pages, limiter, mailer, signer, policy)
 
 	mainRouter := mux.NewRouter()
 	mainRouter.PathPrefix("/api/v1/").Handler(http.StripPrefix("/api/v1", apiRouter))
@@ -69,11 +130,210 @@ func runServer() error {
 	mainRouter.PathPrefix("/login").Handler(adminRouter)
 	mainRouter.PathPrefix("/").Handler(webRouter)
 
-	log.Fatal(http.ListenAndServe(":8080", mainRouter))
+	// Security headers on everything; fingerprinted assets, then any other
+	// static file, then the app
+	var handler http.Handler = mainRouter
+	if files != nil {
+		cacheControl := os.Getenv("STATIC_CACHE_CONTROL")
+		if cacheControl == "" {
+			cacheControl = "public, max-age=604800"
+		}
+		handler = fileserver.New(files, cacheControl).Handler(handler)
+	}
+	handler = manifest.Handler(handler)
+	handler = secure.New(secure.ConfigFromEnv()).Handler(handler)
+
+	log.Fatal(http.ListenAndServe(":8080", handler))
 
 	return nil
 }
 
+// staticFiles returns the files under static/ the app serves itself, or nil
+// when nginx serves them. STATIC_FILES=embed serves the copy built into the
+// binary and STATIC_FILES=disk serves STATIC_DIR (default static).
+func staticFiles() (fs.FS, error) {
+	switch os.Getenv("STATIC_FILES") {
+	case "":
+		return nil, nil
+	case "embed":
+		return static.Files, nil
+	case "disk":
+		dir := os.Getenv("STATIC_DIR")
+		if dir == "" {
+			dir = "static"
+		}
+		return static.WithoutSource(os.DirFS(dir)), nil
+	default:
+		return nil, fmt.Errorf("unknown STATIC_FILES %q; use embed or disk", os.Getenv("STATIC_FILES"))
+	}
+}
+
+// assetsFS returns the directory of static files to fingerprint: the dir
+// directory of the static files the app serves, or of static/. ASSETS_DIR
+// replaces dist.
+func assetsFS(files fs.FS, dir string) fs.FS {
+	if assetsDir := os.Getenv("ASSETS_DIR"); dir == "dist" && assetsDir != "" {
+		return os.DirFS(assetsDir)
+	}
+
+	if files != nil {
+		sub, err := fs.Sub(files, dir)
+		if err == nil {
+			return sub
+		}
+	}
+
+	return os.DirFS(filepath.Join("static", dir))
+}
+
+// newRenderCache builds the article render cache from the environment.
+// RENDER_CACHE_SIZE sets the number of in-process entries and
+// RENDER_CACHE_REDIS=true shares rendered pages through Redis for
+// RENDER_CACHE_TTL seconds.
+func newRenderCache(store *redistore.RediStore) *cache.RenderCache {
+	size, err := strconv.Atoi(os.Getenv("RENDER_CACHE_SIZE"))
+	if err != nil || size <= 0 {
+		size = 256
+	}
+
+	var remote cache.Store
+	if os.Getenv("RENDER_CACHE_REDIS") == "true" {
+		ttl, err := strconv.Atoi(os.Getenv("RENDER_CACHE_TTL"))
+		if err != nil || ttl <= 0 {
+			ttl = 3600
+		}
+
+		remote = &cache.Redis{
+			Pool:   store.Pool,
+			Prefix: "render:",
+			TTL:    time.Duration(ttl) * time.Second,
+		}
+	}
+
+	return cache.NewRenderCache(size, remote)
+}
+
+// newLoginLimiter builds the login throttle from the environment, keeping
+// its counters in Redis. LOGIN_IP_LIMIT failures from one address within
+// LOGIN_IP_WINDOW seconds refuse that address, and LOGIN_LOCK_AFTER
+// consecutive failures lock an account for LOGIN_LOCK_SECONDS. Between
+// failures an account waits LOGIN_DELAY_BASE seconds, doubling up to
+// LOGIN_DELAY_MAX.
+func newLoginLimiter(store *redistore.RediStore) *throttle.Limiter {
+	limiter := throttle.New(&throttle.Redis{Pool: store.Pool, Prefix: "login:"})
+
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_IP_LIMIT")); err == nil {
+		limiter.IPLimit = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_IP_WINDOW")); err == nil && n > 0 {
+		limiter.Window = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_LOCK_AFTER")); err == nil {
+		limiter.LockAfter = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_LOCK_SECONDS")); err == nil && n > 0 {
+		limiter.LockFor = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_DELAY_BASE")); err == nil {
+		limiter.DelayBase = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_DELAY_MAX")); err == nil {
+		limiter.DelayMax = time.Duration(n) * time.Second
+	}
+
+	return limiter
+}
+
+// newHasher builds the password hasher from the environment.
+// PASSWORD_HASH is argon2id (the default) or bcrypt. ARGON2_MEMORY (KiB),
+// ARGON2_TIME and ARGON2_THREADS tune argon2id and BCRYPT_COST tunes
+// bcrypt. Stored hashes made any other way are upgraded on login.
+func newHasher() passwords.Hasher {
+	switch os.Getenv("PASSWORD_HASH") {
+	case "", "argon2id":
+	case "bcrypt":
+		cost, err := strconv.Atoi(os.Getenv("BCRYPT_COST"))
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			cost = 14
+		}
+		return passwords.Bcrypt{Cost: cost}
+	default:
+		log.Fatalf("Unknown PASSWORD_HASH %q; use argon2id or bcrypt", os.Getenv("PASSWORD_HASH"))
+	}
+
+	hasher := passwords.DefaultArgon2id
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil && n >= 8 {
+		hasher.Memory = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_TIME"), 10, 32); err == nil && n > 0 {
+		hasher.Time = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_THREADS"), 10, 8); err == nil && n > 0 {
+		hasher.Threads = uint8(n)
+	}
+
+	return hasher
+}
+
+// newPasswordPolicy builds the rules new passwords must meet from the
+// environment. PASSWORD_MIN_LENGTH and PASSWORD_MIN_ENTROPY (bits) set the
+// floor, and BREACHED_PASSWORDS_DIR points at a downloaded breached
+// password range list to refuse passwords from. Passwords are capped at
+// bcrypt's 72 bytes while it is the hasher.
+func newPasswordPolicy(hasher passwords.Hasher) *passwords.Policy {
+	policy := *passwords.DefaultPolicy
+
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		policy.MinLength = n
+	}
+	if n, err := strconv.ParseFloat(os.Getenv("PASSWORD_MIN_ENTROPY"), 64); err == nil && n >= 0 {
+		policy.MinEntropy = n
+	}
+	if _, ok := hasher.(passwords.Bcrypt); ok {
+		policy.MaxLength = 72
+	}
+
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			log.Fatalf("Unable to read BREACHED_PASSWORDS_DIR: %v", err)
+		}
+		policy.Breached = passwords.NewBreached(dir)
+	}
+
+	return &policy
+}
+
+// tokenSecret returns TOKEN_SECRET, the key emailed links are signed with.
+// Without it a random key is used, and links stop working on restart.
+func tokenSecret() []byte {
+	if secret := os.Getenv("TOKEN_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+
+	log.Println("TOKEN_SECRET is unset; emailed links will not survive a restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Unable to generate a token secret: %v", err)
+	}
+	return secret
+}
+
+func runMigrate() error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		log.Fatalf("Error loading .env file")
+	}
+
+	dbpool, err := pgxpool.New(context.Background(), os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return fmt.Errorf("unable to create connection pool: %v", err)
+	}
+	defer dbpool.Close()
+
+	db := db.DB{DB: dbpool}
+	return db.Migrate()
+}
+
 func hashPassword(password string) {
 	hash, err := passwords.HashPassword(password)
 	if err != nil {
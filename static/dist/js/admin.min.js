@@ -1 +0,0 @@
-document.querySelectorAll("a[href$='/delete']").forEach(e=>{e.addEventListener("click",(function(e){e.preventDefault(),confirm("Are you sure you want to delete this item?")&&(window.location.href=this.getAttribute("href"))}))});
\ No newline at end of file
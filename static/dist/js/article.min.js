@@ -1 +0,0 @@
-var lastY=0;window.addEventListener("scroll",(function(){var e=document.getElementById("MainNav"),l=window.pageYOffset;l>100&&l>lastY?(e.style.display="none",lastY=l):l<=lastY&&(e.style.display="block",lastY=l)})),document.getElementById("ShowMainNav").addEventListener("click",(function(){var e=document.querySelector("nav#MainNav ul");"block"===e.style.display?e.style.display="none":e.style.display="block"})),window.onload=function(){for(var e=document.getElementsByTagName("pre"),l=0;l<e.length;l++)hljs.highlightBlock(e[l])};
\ No newline at end of file
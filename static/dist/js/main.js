@@ -1,85 +1,47 @@
-if(document.getElementById('MainNav')!==null){
+var lastY = 0;
 
-  window.addEventListener(
-    'scroll',
+window.addEventListener('scroll', function(){
 
-    function(){
+  var nav = document.getElementById('MainNav');
+  var yPosition = window.pageYOffset;
 
-      var nav = document.getElementById('MainNav');
-      var yPosition = window.pageYOffset;
-
-      if(yPosition > 10) {
-        nav.style.display='block';
-      }else{
-        nav.style.display='none';
-      }
-
-    }
-
-  );
-
-  document.getElementById('ToAbout').addEventListener(
-    'click',
-    function(e) {
-
-
-      var el = document.getElementById('About');
-      var rect = el.getBoundingClientRect();
-      window.scrollTo({
-          top: (rect.top + window.pageYOffset) - 60,
-          behavior: "smooth"
-      });
-
-      history.pushState(null, null, '#ToAbout');
-      e.preventDefault();
-    }
-  );
-
-  document.getElementById('ToAboutNext').addEventListener(
-    'click',
-    function(e) {
-
-
-      var el = document.getElementById('About');
-      var rect = el.getBoundingClientRect();
+  if(yPosition > 100 && yPosition > lastY){
+    nav.style.display='none';
+    lastY = yPosition;
+  }else if(yPosition <= lastY){
+    nav.style.display='block';
+    lastY = yPosition;
+  }
 
-      window.scrollTo({
-          top: (rect.top + window.pageYOffset) - 60,
-          behavior: "smooth"
-      });
+});
 
-      history.pushState(null, null, '#ToAbout');
-      e.preventDefault();
-    }
-  );
 
-  document.getElementById('ToProject').addEventListener(
-    'click',
-    function(e) {
+document.getElementById('ShowMainNav').addEventListener('click',function(){
 
+  var nav = document.querySelector('nav#MainNav ul');
 
-      var el = document.getElementById('Project');
-      var rect = el.getBoundingClientRect();
-      window.scrollTo({
-          top: (rect.top + window.pageYOffset) - 60,
-          behavior: "smooth"
-      });
+  if (nav.style.display === "block") {
+      nav.style.display = "none";
+  } else {
+      nav.style.display = "block";
+  }
 
-      history.pushState(null, null, '#ToProject');
-      e.preventDefault();
-    }
-  );
+});
 
-}
+/*
+var media = window.matchMedia("screen and (max-device-width : 450px)");
+media.addEventListener('change',function(){
 
+  var nav = document.querySelector('nav#mainNav ul');
 
-/* Highlight.js */
-window.onload = function() {
-  var aCodes = document.getElementsByTagName('pre');
-  for (var i=0; i < aCodes.length; i++) {
-      hljs.highlightBlock(aCodes[i]);
+  if(media.matches===false){
+    nav.style.display = "block";
+  }else{
+    nav.style.display = "none";
   }
-};
+
+});
+*/
 /* Load Defered styles */
 /*
 var loadDeferredStyles = function() {
@@ -1 +1 @@
-null!==document.getElementById("MainNav")&&(window.addEventListener("scroll",function(){var t=document.getElementById("MainNav"),e=window.pageYOffset;t.style.display=10<e?"block":"none"}),document.getElementById("ToAbout").addEventListener("click",function(t){var e=document.getElementById("About").getBoundingClientRect();window.scrollTo({top:e.top+window.pageYOffset-60,behavior:"smooth"}),history.pushState(null,null,"#ToAbout"),t.preventDefault()}),document.getElementById("ToAboutNext").addEventListener("click",function(t){var e=document.getElementById("About").getBoundingClientRect();window.scrollTo({top:e.top+window.pageYOffset-60,behavior:"smooth"}),history.pushState(null,null,"#ToAbout"),t.preventDefault()}),document.getElementById("ToProject").addEventListener("click",function(t){var e=document.getElementById("Project").getBoundingClientRect();window.scrollTo({top:e.top+window.pageYOffset-60,behavior:"smooth"}),history.pushState(null,null,"#ToProject"),t.preventDefault()})),window.onload=function(){for(var t=document.getElementsByTagName("pre"),e=0;e<t.length;e++)hljs.highlightBlock(t[e])};
\ No newline at end of file
+var lastY=0;window.addEventListener("scroll",(function(){var e=document.getElementById("MainNav"),l=window.pageYOffset;l>100&&l>lastY?(e.style.display="none",lastY=l):l<=lastY&&(e.style.display="block",lastY=l)})),document.getElementById("ShowMainNav").addEventListener("click",(function(){var e=document.querySelector("nav#MainNav ul");"block"===e.style.display?e.style.display="none":e.style.display="block"}));
\ No newline at end of file
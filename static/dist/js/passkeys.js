@@ -0,0 +1,120 @@
+// Passkey sign in and registration. The server sends WebAuthn options as
+// JSON with binary fields in base64url, and takes the browser's answer back
+// the same way.
+(function () {
+
+    function decode(value) {
+        var base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        var binary = atob(base64);
+        var bytes = new Uint8Array(binary.length);
+        for (var i = 0; i < binary.length; i++) {
+            bytes[i] = binary.charCodeAt(i);
+        }
+        return bytes.buffer;
+    }
+
+    function encode(buffer) {
+        var bytes = new Uint8Array(buffer);
+        var binary = '';
+        for (var i = 0; i < bytes.length; i++) {
+            binary += String.fromCharCode(bytes[i]);
+        }
+        return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    function post(url, csrf, body) {
+        return fetch(url, {
+            method: 'POST',
+            credentials: 'same-origin',
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': csrf },
+            body: body ? JSON.stringify(body) : null
+        }).then(function (response) {
+            return response.json().then(function (data) {
+                if (!response.ok) {
+                    throw new Error(data.error || 'Something went wrong, please try again');
+                }
+                return data;
+            });
+        });
+    }
+
+    function credentialJSON(credential) {
+        var response = {
+            clientDataJSON: encode(credential.response.clientDataJSON)
+        };
+
+        if (credential.response.attestationObject) {
+            response.attestationObject = encode(credential.response.attestationObject);
+            if (credential.response.getTransports) {
+                response.transports = credential.response.getTransports();
+            }
+        } else {
+            response.authenticatorData = encode(credential.response.authenticatorData);
+            response.signature = encode(credential.response.signature);
+            if (credential.response.userHandle) {
+                response.userHandle = encode(credential.response.userHandle);
+            }
+        }
+
+        return {
+            id: credential.id,
+            rawId: encode(credential.rawId),
+            type: credential.type,
+            authenticatorAttachment: credential.authenticatorAttachment,
+            response: response
+        };
+    }
+
+    function showError(container, error) {
+        var message = container.querySelector('[data-passkey-error]');
+        if (message) {
+            message.textContent = error.name === 'NotAllowedError' ? 'The passkey request was cancelled' : error.message;
+        }
+    }
+
+    document.querySelectorAll('[data-passkey-login]').forEach(function (container) {
+        var csrf = container.getAttribute('data-csrf');
+
+        container.querySelector('button').addEventListener('click', function () {
+            post('/admin/login/passkey/options', csrf).then(function (options) {
+                options.publicKey.challenge = decode(options.publicKey.challenge);
+                (options.publicKey.allowCredentials || []).forEach(function (credential) {
+                    credential.id = decode(credential.id);
+                });
+                return navigator.credentials.get(options);
+            }).then(function (credential) {
+                return post('/admin/login/passkey', csrf, credentialJSON(credential));
+            }).then(function (data) {
+                window.location.href = data.redirect;
+            }).catch(function (error) {
+                showError(container, error);
+            });
+        });
+    });
+
+    document.querySelectorAll('form[data-passkey-register]').forEach(function (form) {
+        var csrf = form.querySelector('input[name="csrf_token"]').value;
+
+        form.addEventListener('submit', function (e) {
+            e.preventDefault();
+
+            var name = form.querySelector('input[name="name"]').value;
+
+            post('/admin/account/passkeys/options', csrf).then(function (options) {
+                options.publicKey.challenge = decode(options.publicKey.challenge);
+                options.publicKey.user.id = decode(options.publicKey.user.id);
+                (options.publicKey.excludeCredentials || []).forEach(function (credential) {
+                    credential.id = decode(credential.id);
+                });
+                return navigator.credentials.create(options);
+            }).then(function (credential) {
+                return post('/admin/account/passkeys?name=' + encodeURIComponent(name), csrf, credentialJSON(credential));
+            }).then(function (data) {
+                window.location.href = data.redirect;
+            }).catch(function (error) {
+                showError(form, error);
+            });
+        });
+    });
+
+})();
@@ -0,0 +1 @@
+!function(){function e(e){for(var t=atob(e.replace(/-/g,"+").replace(/_/g,"/")),n=new Uint8Array(t.length),r=0;r<t.length;r++)n[r]=t.charCodeAt(r);return n.buffer}function t(e){for(var t=new Uint8Array(e),n="",r=0;r<t.length;r++)n+=String.fromCharCode(t[r]);return btoa(n).replace(/\+/g,"-").replace(/\//g,"_").replace(/=+$/,"")}function n(e,t,n){return fetch(e,{method:"POST",credentials:"same-origin",headers:{"Content-Type":"application/json","X-CSRF-Token":t},body:n?JSON.stringify(n):null}).then((function(e){return e.json().then((function(t){if(!e.ok)throw new Error(t.error||"Something went wrong, please try again");return t}))}))}function r(e){var n={clientDataJSON:t(e.response.clientDataJSON)};return e.response.attestationObject?(n.attestationObject=t(e.response.attestationObject),e.response.getTransports&&(n.transports=e.response.getTransports())):(n.authenticatorData=t(e.response.authenticatorData),n.signature=t(e.response.signature),e.response.userHandle&&(n.userHandle=t(e.response.userHandle))),{id:e.id,rawId:t(e.rawId),type:e.type,authenticatorAttachment:e.authenticatorAttachment,response:n}}function o(e,t){var n=e.querySelector("[data-passkey-error]");n&&(n.textContent="NotAllowedError"===t.name?"The passkey request was cancelled":t.message)}document.querySelectorAll("[data-passkey-login]").forEach((function(t){var a=t.getAttribute("data-csrf");t.querySelector("button").addEventListener("click",(function(){n("/admin/login/passkey/options",a).then((function(t){return t.publicKey.challenge=e(t.publicKey.challenge),(t.publicKey.allowCredentials||[]).forEach((function(t){t.id=e(t.id)})),navigator.credentials.get(t)})).then((function(e){return n("/admin/login/passkey",a,r(e))})).then((function(e){window.location.href=e.redirect})).catch((function(e){o(t,e)}))}))})),document.querySelectorAll("form[data-passkey-register]").forEach((function(t){var a=t.querySelector('input[name="csrf_token"]').value;t.addEventListener("submit",(function(c){c.preventDefault();var i=t.querySelector('input[name="name"]').value;n("/admin/account/passkeys/options",a).then((function(t){return t.publicKey.challenge=e(t.publicKey.challenge),t.publicKey.user.id=e(t.publicKey.user.id),(t.publicKey.excludeCredentials||[]).forEach((function(t){t.id=e(t.id)})),navigator.credentials.create(t)})).then((function(e){return n("/admin/account/passkeys?name="+encodeURIComponent(i),a,r(e))})).then((function(e){window.location.href=e.redirect})).catch((function(e){o(t,e)}))}))}))}();
\ No newline at end of file
@@ -0,0 +1,34 @@
+// Package static embeds the public files nginx serves from /app/static, so
+// the binary can serve them itself when it runs without nginx.
+package static
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
+
+//go:embed *
+var files embed.FS
+
+// Files holds every file under static/ except this package's source.
+var Files fs.FS = WithoutSource(files)
+
+// WithoutSource hides this package's source in fsys, for serving static/
+// from disk as well as from the embedded copy.
+func WithoutSource(fsys fs.FS) fs.FS {
+	return withoutSource{fsys}
+}
+
+// withoutSource only implements Open, so every other fs helper goes
+// through the filter too.
+type withoutSource struct {
+	fsys fs.FS
+}
+
+func (f withoutSource) Open(name string) (fs.File, error) {
+	if path.Ext(name) == ".go" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return f.fsys.Open(name)
+}
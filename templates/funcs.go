@@ -0,0 +1,31 @@
+package templates
+
+import (
+	"html/template"
+
+	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/render"
+)
+
+// FuncMap is shared by every layout, partial and page template.
+var FuncMap = template.FuncMap{
+	"safeValue": types.SafeValue,
+	"mdToHTML":  mdToHTML,
+	"render":    renderBody,
+	"path":      assetPath,
+}
+
+func mdToHTML(md string) template.HTML {
+	return render.Markdown(md, render.DefaultMarkdownOptions)
+}
+
+// renderBody renders an article body with the renderer for its format.
+func renderBody(format, body string) template.HTML {
+	return render.Render(render.Format(format), body)
+}
+
+// assetPath returns an asset URL unchanged. The server replaces it with the
+// asset manifest's Path so URLs are fingerprinted.
+func assetPath(url string) string {
+	return url
+}
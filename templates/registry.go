@@ -0,0 +1,241 @@
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pages maps each page template to the layout it renders inside. The page
+// name is also the name of the source that defines its "content" block.
+var pages = map[string]string{
+	"web/home":                "layouts/home",
+	"web/articles":            "layouts/main",
+	"web/article":             "layouts/main",
+	"web/games":               "layouts/main",
+	"web/tools":               "layouts/main",
+	"web/tool":                "layouts/main",
+	"web/contact":             "layouts/main",
+	"admin/login":             "layouts/main",
+	"admin/forgot_password":   "layouts/main",
+	"admin/reset_password":    "layouts/main",
+	"admin/verify_email":      "layouts/main",
+	"admin/login_2fa":         "layouts/main",
+	"admin/register":          "layouts/admin",
+	"admin/dashboard":         "layouts/admin",
+	"admin/account_2fa":       "layouts/admin",
+	"admin/recovery_codes":    "layouts/admin",
+	"admin/passkeys":          "layouts/admin",
+	"admin/sessions":          "layouts/admin",
+	"admin/audit":             "layouts/admin",
+	"admin/users":             "layouts/admin",
+	"admin/user":              "layouts/admin",
+	"admin/user_create":       "layouts/admin",
+	"admin/user_edit":         "layouts/admin",
+	"admin/user_delete":       "layouts/admin",
+	"admin/invitations":       "layouts/admin",
+	"admin/invitation_create": "layouts/admin",
+	"admin/articles":          "layouts/admin",
+	"admin/article":           "layouts/admin",
+	"admin/article_create":    "layouts/admin",
+	"admin/article_edit":      "layouts/admin",
+	"admin/article_delete":    "layouts/admin",
+}
+
+//go:embed layouts partials web admin
+var files embed.FS
+
+// Registry holds every page template parsed once at startup. Each template
+// is read from the theme directory when it has a copy, otherwise from the
+// embedded files. In dev mode the embedded files are replaced by dir on disk
+// and every render re-parses the page, so templates can be edited without
+// restarting the server.
+type Registry struct {
+	base  fs.FS
+	theme fs.FS
+	dev   bool
+	funcs template.FuncMap
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// NewRegistry parses every page and returns an error if any of them fails,
+// so a broken template stops the server from booting. funcs are added to
+// FuncMap, replacing any built-in function of the same name.
+func NewRegistry(dir, theme string, dev bool, funcs template.FuncMap) (*Registry, error) {
+	r := &Registry{
+		base:  files,
+		dev:   dev,
+		funcs: template.FuncMap{},
+		pages: make(map[string]*template.Template),
+	}
+
+	for name, fn := range FuncMap {
+		r.funcs[name] = fn
+	}
+	for name, fn := range funcs {
+		r.funcs[name] = fn
+	}
+
+	if dev && dir != "" {
+		r.base = os.DirFS(dir)
+	}
+
+	if theme != "" {
+		r.theme = os.DirFS(theme)
+	}
+
+	for name := range pages {
+		tmpl, err := r.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		r.pages[name] = tmpl
+	}
+
+	return r, nil
+}
+
+// Render executes the named page into w. The output is buffered so a failed
+// execution never leaves a half written response.
+func (r *Registry) Render(w io.Writer, name string, data interface{}) error {
+	tmpl, err := r.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.ExecuteTemplate(&buf, "layout", data)
+	if err != nil {
+		return err
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func (r *Registry) lookup(name string) (*template.Template, error) {
+	if r.dev {
+		tmpl, err := r.parse(name)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.pages[name] = tmpl
+		r.mu.Unlock()
+
+		return tmpl, nil
+	}
+
+	r.mu.RLock()
+	tmpl, ok := r.pages[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+
+	return tmpl, nil
+}
+
+// parse builds a page from its layout, every partial and its content.
+func (r *Registry) parse(name string) (*template.Template, error) {
+	layout, ok := pages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q", name)
+	}
+
+	partials, err := r.partials()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := template.New("layout").Funcs(r.funcs)
+	for i, source := range append([]string{layout, name}, partials...) {
+		text, err := r.source(source)
+		if err != nil {
+			return nil, err
+		}
+
+		// The layout is the root template, executed as "layout".
+		t := tmpl
+		if i > 0 {
+			t = tmpl.New(source)
+		}
+
+		_, err = t.Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s for %s: %v", source, name, err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// partials lists every template under partials/, including any the theme
+// adds on top of the built-in ones.
+func (r *Registry) partials() ([]string, error) {
+	seen := make(map[string]bool)
+	var names []string
+
+	for _, fsys := range []fs.FS{r.base, r.theme} {
+		if fsys == nil {
+			continue
+		}
+
+		err := fs.WalkDir(fsys, "partials", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipDir
+				}
+				return err
+			}
+
+			name := strings.TrimSuffix(path, ".html")
+			if d.IsDir() || name == path || seen[name] {
+				return nil
+			}
+
+			seen[name] = true
+			names = append(names, name)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// source returns the text of a template, preferring the theme's copy.
+func (r *Registry) source(name string) (string, error) {
+	file := name + ".html"
+
+	if r.theme != nil {
+		contents, err := fs.ReadFile(r.theme, file)
+		if err == nil {
+			return string(contents), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	contents, err := fs.ReadFile(r.base, file)
+	if err != nil {
+		return "", fmt.Errorf("missing template source %q: %v", name, err)
+	}
+
+	return string(contents), nil
+}
@@ -0,0 +1,185 @@
+package templates
+
+import (
+	"database/sql"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type testPage struct {
+	Title string
+	Nonce string
+	CSRF  string
+}
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+
+	path := filepath.Join(dir, name+".html")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewRegistryParsesEveryPage(t *testing.T) {
+	r, err := NewRegistry("", "", false, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	for name := range pages {
+		if _, ok := r.pages[name]; !ok {
+			t.Errorf("page %q was not parsed", name)
+		}
+	}
+}
+
+func TestRenderUnknownPage(t *testing.T) {
+	r, err := NewRegistry("", "", false, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := r.Render(&out, "web/missing", nil); err == nil {
+		t.Fatalf("Render returned no error for an unknown page")
+	}
+}
+
+func TestThemeOverridesTemplates(t *testing.T) {
+	theme := t.TempDir()
+	writeTemplate(t, theme, "partials/admin_nav", `{{define "admin_nav"}}themed nav{{end}}`)
+	writeTemplate(t, theme, "admin/dashboard", `{{define "content"}}themed {{template "extra" .}}{{end}}`)
+	writeTemplate(t, theme, "partials/extra", `{{define "extra"}}partial{{end}}`)
+
+	r, err := NewRegistry("", theme, false, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := r.Render(&out, "admin/dashboard", testPage{Title: "Dashboard"}); err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+
+	for _, want := range []string{"themed nav", "themed partial", "<title>Dashboard</title>"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Render output does not contain %q", want)
+		}
+	}
+}
+
+func TestBrokenThemeFailsBoot(t *testing.T) {
+	theme := t.TempDir()
+	writeTemplate(t, theme, "partials/footer", `{{define "footer"}}{{end`)
+
+	if _, err := NewRegistry("", theme, false, nil); err == nil {
+		t.Fatalf("NewRegistry returned no error for a broken template")
+	}
+}
+
+func TestDevModeReloadsFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.CopyFS(dir, files); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRegistry(dir, "", true, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	for _, want := range []string{"first edit", "second edit"} {
+		writeTemplate(t, dir, "admin/dashboard", `{{define "content"}}`+want+`{{end}}`)
+
+		var out strings.Builder
+		if err := r.Render(&out, "admin/dashboard", testPage{}); err != nil {
+			t.Fatalf("Render returned an error: %v", err)
+		}
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Render output does not contain %q", want)
+		}
+	}
+
+	writeTemplate(t, dir, "admin/dashboard", `{{define "content"}}{{end`)
+
+	var out strings.Builder
+	if err := r.Render(&out, "admin/dashboard", testPage{}); err == nil {
+		t.Errorf("Render returned no error for a broken template")
+	}
+}
+
+func TestFuncsReplaceBuiltins(t *testing.T) {
+	funcs := template.FuncMap{
+		"path": func(url string) string { return url + "?fingerprinted" },
+	}
+
+	r, err := NewRegistry("", "", false, funcs)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := r.Render(&out, "admin/dashboard", testPage{}); err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+
+	want := "/dist/css/admin.min.css?fingerprinted"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("Render output does not contain %q", want)
+	}
+}
+
+func TestLayoutsCarryNonce(t *testing.T) {
+	r, err := NewRegistry("", "", false, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	page := struct {
+		Title       string
+		Description sql.NullString
+		Keywords    sql.NullString
+		Body        string
+		Nonce       string
+	}{Title: "Home", Nonce: "{nonce}"}
+
+	var out strings.Builder
+	if err := r.Render(&out, "web/home", page); err != nil {
+		t.Fatalf("Render returned an error: %v", err)
+	}
+	if !strings.Contains(out.String(), `<script nonce="{nonce}">`) {
+		t.Errorf("Render output has no inline script carrying the nonce")
+	}
+}
+
+// csrfField matches the CSRF partial, passed the page data directly or as $
+// from inside a range.
+var csrfField = regexp.MustCompile(`\{\{template "csrf" [.$]\}\}`)
+
+func TestFormsCarryCSRFToken(t *testing.T) {
+	r, err := NewRegistry("", "", false, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry returned an error: %v", err)
+	}
+
+	for name := range pages {
+		text, err := r.source(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forms := strings.Count(text, `method="POST"`)
+		tokens := len(csrfField.FindAllString(text, -1))
+		if forms != tokens {
+			t.Errorf("%s has %d POST forms but %d CSRF fields", name, forms, tokens)
+		}
+	}
+}
@@ -1,38 +1,33 @@
 package web
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
-	"html/template"
 	"net/http"
 
-	"github.com/gomarkdown/markdown"
 	"github.com/gorilla/mux"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/httpcache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 )
 
 type ArticlesPageData struct {
-	Title        string
-	Description  sql.NullString
-	Keywords     sql.NullString
-	Articles     []types.Article
-	BustCssCache string
-	BustJsCache  string
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Articles    []types.Article
+	Nonce       string
 }
 
 type ArticlePageData struct {
-	Title        string
-	Description  sql.NullString
-	Keywords     sql.NullString
-	Body         string
-	BustCssCache string
-	BustJsCache  string
-}
-
-func mdToHTML(md string) template.HTML {
-	return template.HTML(markdown.ToHTML([]byte(md), nil, nil))
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Body        string
+	Format      string
+	Nonce       string
 }
 
 func (app *App) ListArticles(w http.ResponseWriter, r *http.Request) {
@@ -45,35 +40,15 @@ func (app *App) ListArticles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	articlesTemplate := `
-        {{define "content"}}
-            <h1>Articles</h1>
-            <div>
-                {{range .Articles}}
-                    <h2><a href="/articles/{{.Slug}}">{{.Title}}</a></h2>
-                    <p>{{ safeValue .Description}}</p>
-                {{end}}
-            </div>
-        {{end}}
-    `
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("content").Parse(articlesTemplate))
-
 	pageData := ArticlesPageData{
-		Title:        "Articles",
-		Description:  types.TypeSqlNullString("A list of articles"),
-		Keywords:     types.TypeSqlNullString("articles, blog"),
-		Articles:     articles,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       "Articles",
+		Description: types.TypeSqlNullString("A list of articles"),
+		Keywords:    types.TypeSqlNullString("articles, blog"),
+		Articles:    articles,
+		Nonce:       secure.Nonce(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "web/articles", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -84,6 +59,12 @@ func (app *App) ViewArticle(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	slug := vars["slug"]
 
+	if page, updated, ok := app.Pages.Get(r.URL.Path); ok {
+		httpcache.SetLastModified(w, updated)
+		w.Write(withNonce(page, secure.Nonce(r)))
+		return
+	}
+
 	var article types.Article
 	article, err := db.FetchArticleBySlug(slug)
 
@@ -92,35 +73,32 @@ func (app *App) ViewArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"mdToHTML":  mdToHTML,
-		"safeValue": types.SafeValue,
-	}
-
-	articleTemplate := `
-		{{define "content"}}
-			<h1>{{.Title}}</h1>
-			<div>
-				{{mdToHTML .Body}}
-			</div>
-		{{end}}
-    `
-
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(articleTemplate))
-
 	pageData := ArticlePageData{
-		Title:        article.Title,
-		Description:  article.Description,
-		Keywords:     article.Keywords,
-		Body:         article.Body.String,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       article.Title,
+		Description: article.Description,
+		Keywords:    article.Keywords,
+		Body:        article.Body.String,
+		Format:      article.Format.String,
+		// Cached pages are shared between requests, so the nonce is
+		// filled in as each one is written.
+		Nonce: secure.NoncePlaceholder,
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	var page bytes.Buffer
+	err = app.Templates.Render(&page, "web/article", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
+		return
 	}
+
+	app.Pages.Set(r.URL.Path, article.ID, article.Updated, page.Bytes())
+	httpcache.SetLastModified(w, article.Updated)
+	w.Write(withNonce(page.Bytes(), secure.Nonce(r)))
+}
+
+// withNonce fills the request's nonce into a page rendered with the
+// placeholder.
+func withNonce(page []byte, nonce string) []byte {
+	placeholder := []byte(`nonce="` + secure.NoncePlaceholder + `"`)
+	return bytes.ReplaceAll(page, placeholder, []byte(`nonce="`+nonce+`"`))
 }
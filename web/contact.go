@@ -1,16 +1,25 @@
 package web
 
 import (
+	"database/sql"
 	"fmt"
-	"html/template"
 	"net/http"
 	"os"
 
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 	"github.com/mailgun/mailgun-go"
 )
 
+type ContactPageData struct {
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Nonce       string
+	CSRF        string
+}
+
 type Contact struct {
 	Subject string `json:"subject"`
 	Name    string `json:"name"`
@@ -51,53 +60,15 @@ func (app *App) Contact(w http.ResponseWriter, r *http.Request) {
 		SendSimpleMessage(contact)
 	}
 
-	contactTemplate := `
-	{{define "content"}}
-		<h1>Contact</h1>
-		<form action="/contact" method="POST">
-			<input type="hidden" name="_next" value="https://jasonsnider.com/thanks">
-			<div>
-				<label for="subject">Subject</label>
-				<select id="subject" name="_subject">
-					<option value="CONTACT: jasonsnider.com">General Contact</option>
-					<option value="SUPPORT: jasonsnider.com">Support</option>
-				</select>
-			</div>
-			<div>
-				<label for="subject">Name</label><input type="text" id="name" name="name">
-			</div>
-			<div>
-				<label for="subject">Email</label>
-				<input type="email" id="email" name="_replyto">
-			</div>
-			<div>
-				<label for="body">Body</label>
-				<textarea id="body" name="body" rows="5" spellcheck="false"></textarea>
-			</div>
-			<div>
-				<input type="submit">
-			</div>
-		</form>
-	{{end}}
-    `
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	tmpl := template.Must(template.New("layout").Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Funcs(funcMap).Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(contactTemplate))
-
-	pageData := ArticlePageData{
-		Title:        "Contact",
-		Description:  types.TypeSqlNullString("Contact Jason Snider"),
-		Keywords:     types.TypeSqlNullString("contact, email"),
-		Body:         contactTemplate,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+	pageData := ContactPageData{
+		Title:       "Contact",
+		Description: types.TypeSqlNullString("Contact Jason Snider"),
+		Keywords:    types.TypeSqlNullString("contact, email"),
+		Nonce:       secure.Nonce(r),
+		CSRF:        csrf.Token(r),
 	}
 
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	err := app.Templates.Render(w, "web/contact", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -2,13 +2,13 @@ package web
 
 import (
 	"fmt"
-	"html/template"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/httpcache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 )
 
 func (app *App) ListGames(w http.ResponseWriter, r *http.Request) {
@@ -28,35 +28,15 @@ func (app *App) ListGames(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	articlesTemplate := `
-        {{define "content"}}
-            <h1>Games</h1>
-            <div>
-                {{range .Articles}}
-                    <h2><a href="/games/{{.Slug}}">{{.Title}}</a></h2>
-                    <p>{{safeValue .Description}}</p>
-                {{end}}
-            </div>
-        {{end}}
-    `
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Funcs(funcMap).Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("content").Parse(articlesTemplate))
-
 	pageData := ArticlesPageData{
-		Title:        meta.Title,
-		Description:  meta.Description,
-		Keywords:     meta.Keywords,
-		Articles:     articles,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Keywords:    meta.Keywords,
+		Articles:    articles,
+		Nonce:       secure.Nonce(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "web/games", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -75,34 +55,18 @@ func (app *App) ViewGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"mdToHTML":  mdToHTML,
-		"safeValue": types.SafeValue,
-	}
-
-	articleTemplate := `
-		{{define "content"}}
-			<h1>{{.Title}}</h1>
-			<div>
-				{{mdToHTML .Body}}
-			</div>
-		{{end}}
-    `
-
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(articleTemplate))
+	httpcache.SetLastModified(w, article.Updated)
 
 	pageData := ArticlePageData{
-		Title:        article.Title,
-		Description:  article.Description,
-		Keywords:     article.Keywords,
-		Body:         article.Body.String,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       article.Title,
+		Description: article.Description,
+		Keywords:    article.Keywords,
+		Body:        article.Body.String,
+		Format:      article.Format.String,
+		Nonce:       secure.Nonce(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "web/article", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -3,22 +3,23 @@ package web
 import (
 	"database/sql"
 	"fmt"
-	"html/template"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/db"
 	"github.com/jasonsnider/com.jasonsnider.go/internal/types"
-	"github.com/jasonsnider/com.jasonsnider.go/templates"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/httpcache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/secure"
 )
 
 type ToolPageData struct {
-	Title        string
-	Description  sql.NullString
-	Keywords     sql.NullString
-	Body         template.HTML
-	BustCssCache string
-	BustJsCache  string
+	Title       string
+	Description sql.NullString
+	Keywords    sql.NullString
+	Body        string
+	Format      string
+	Tool        string
+	Nonce       string
 }
 
 func (app *App) ListTools(w http.ResponseWriter, r *http.Request) {
@@ -38,35 +39,15 @@ func (app *App) ListTools(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	articlesTemplate := `
-        {{define "content"}}
-            <h1>Tools</h1>
-            <div>
-                {{range .Articles}}
-                    <h2><a href="/tools/{{.Slug}}">{{.Title}}</a></h2>
-                    <p>{{safeValue .Description}}</p>
-                {{end}}
-            </div>
-        {{end}}
-    `
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("content").Parse(articlesTemplate))
-
 	pageData := ArticlesPageData{
-		Title:        meta.Title,
-		Description:  meta.Description,
-		Keywords:     meta.Keywords,
-		Articles:     articles,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Keywords:    meta.Keywords,
+		Articles:    articles,
+		Nonce:       secure.Nonce(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "web/tools", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
@@ -85,65 +66,19 @@ func (app *App) ViewTool(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	funcMap := template.FuncMap{
-		"mdToHTML": mdToHTML,
-	}
-
-	tools := map[string]string{
-		"hash": `
-            <div>
-                <label for="InputCount">Iterations</label><input id="InputCount" type="text" value="1">
-                <label for="InputString">Enter a string to return a list of hash values</label>
-                <textarea id="InputString" rows="5"></textarea>
-            </div>
-            <div>
-                <small>This utility uses only front end JavaScript no data is sent to the server.</small>
-            </div>
-            <div id="Hashes"></div>
-            <script src="dist/js/tools/hash.min.js"></script>`,
-		"strlen": `
-			<div>
-				<label for="InputString">Enter a string to calculate it's length
-					<span class="hide" id="Results">&nbsp;(<strong id="StringLength"></strong>)</span>
-				</label>
-				<textarea id="InputString" rows="5" spellcheck="false"></textarea></div>
-			<div>
-				<small>This utility uses only front end JavaScript no data is sent to the server.</small>
-			</div>
-			<script src="/dist/js/tools/strlen.js"></script>`,
-	}
-
-	selectedTool := tools[article.Slug]
-
-	body := mdToHTML(article.Body.String) + template.HTML(selectedTool)
-
-	articleTemplate := `
-		{{define "content"}}
-			<h1>{{.Title}}</h1>
-			<div>
-				{{.Body}}
-			</div>
-		{{end}}
-	`
-
-	funcMap = template.FuncMap{
-		"safeValue": types.SafeValue,
-	}
-
-	tmpl := template.Must(template.New("layout").Funcs(funcMap).Parse(templates.MainLayoutTemplate))
-	tmpl = template.Must(tmpl.New("meta").Funcs(funcMap).Parse(templates.MetaDataTemplate))
-	tmpl = template.Must(tmpl.New("article").Parse(articleTemplate))
+	httpcache.SetLastModified(w, article.Updated)
 
 	pageData := ToolPageData{
-		Title:        article.Title,
-		Description:  article.Description,
-		Keywords:     article.Keywords,
-		Body:         body,
-		BustCssCache: app.BustCssCache,
-		BustJsCache:  app.BustJsCache,
+		Title:       article.Title,
+		Description: article.Description,
+		Keywords:    article.Keywords,
+		Body:        article.Body.String,
+		Format:      article.Format.String,
+		Tool:        article.Slug,
+		Nonce:       secure.Nonce(r),
 	}
 
-	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	err = app.Templates.Render(w, "web/tool", pageData)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Template execution failed: %v", err), http.StatusInternalServerError)
 	}
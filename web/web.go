@@ -1,37 +1,55 @@
 package web
 
 import (
+	"net/http"
+	"os"
+
+	"github.com/boj/redistore"
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jasonsnider/com.jasonsnider.go/pkg/cache"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/compress"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/csrf"
+	"github.com/jasonsnider/com.jasonsnider.go/pkg/httpcache"
+	"github.com/jasonsnider/com.jasonsnider.go/templates"
 )
 
 type App struct {
-	DB           *pgxpool.Pool
-	BustCssCache string
-	BustJsCache  string
+	DB        *pgxpool.Pool
+	Templates *templates.Registry
+	Pages     *cache.RenderCache
 }
 
-func WebRouter(dbpool *pgxpool.Pool) *mux.Router {
+func WebRouter(dbpool *pgxpool.Pool, tmpl *templates.Registry, store *redistore.RediStore, pages *cache.RenderCache) *mux.Router {
 	app := &App{
-		DB:           dbpool,
-		BustCssCache: cache.BustCssCache(),
-		BustJsCache:  cache.BustJsCache(),
+		DB:        dbpool,
+		Templates: tmpl,
+		Pages:     pages,
 	}
 
+	// Cache-Control policies for each group of routes. Pages carry a CSP
+	// nonce, so they are private to the browser that got it.
+	static := httpcache.New(os.Getenv("CACHE_CONTROL_PAGES"), "private, max-age=300")
+	articles := httpcache.New(os.Getenv("CACHE_CONTROL_ARTICLES"), "private, max-age=600")
+	forms := httpcache.New(os.Getenv("CACHE_CONTROL_FORMS"), "no-store")
+
+	// Only form routes touch the session, so other pages stay cacheable
+	protect := csrf.New(store, "com-jasonsnider-go")
+
 	router := mux.NewRouter()
-	router.HandleFunc("/", app.Home).Methods("GET")
-	router.HandleFunc("/articles", app.ListArticles).Methods("GET")
-	router.HandleFunc("/articles/{slug}", app.ViewArticle).Methods("GET")
+	router.Use(compress.Handler)
+	router.Handle("/", static.HandlerFunc(app.Home)).Methods("GET")
+	router.Handle("/articles", articles.HandlerFunc(app.ListArticles)).Methods("GET")
+	router.Handle("/articles/{slug}", articles.HandlerFunc(app.ViewArticle)).Methods("GET")
 
-	router.HandleFunc("/games", app.ListGames).Methods("GET")
-	router.HandleFunc("/games/{slug}", app.ViewGame).Methods("GET")
+	router.Handle("/games", articles.HandlerFunc(app.ListGames)).Methods("GET")
+	router.Handle("/games/{slug}", articles.HandlerFunc(app.ViewGame)).Methods("GET")
 
-	router.HandleFunc("/tools", app.ListTools).Methods("GET")
-	router.HandleFunc("/tools/{slug}", app.ViewTool).Methods("GET")
+	router.Handle("/tools", articles.HandlerFunc(app.ListTools)).Methods("GET")
+	router.Handle("/tools/{slug}", articles.HandlerFunc(app.ViewTool)).Methods("GET")
 
-	router.HandleFunc("/contact", app.Contact).Methods("GET")
-	router.HandleFunc("/contact", app.Contact).Methods("POST")
+	router.Handle("/contact", protect.Handler(forms.HandlerFunc(app.Contact))).Methods("GET")
+	router.Handle("/contact", protect.Handler(http.HandlerFunc(app.Contact))).Methods("POST")
 
 	return router
 }